@@ -0,0 +1,78 @@
+package sqlnt
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ArgConverterFunc is the function signature for funcs that can be passed to
+// NamedTemplate.ArgConverter
+type ArgConverterFunc func(v any) (any, error)
+
+var (
+	NullableZeroConverter ArgConverterFunc = nullableZero // converts zero values (e.g. 0, "", zero time.Time, empty slices & maps) to nil
+	TrimConverter         ArgConverterFunc = trimString   // trims leading and trailing white space from string values
+	LowerCaseConverter    ArgConverterFunc = lowerString  // converts string values to lower case
+	JsonConverter         ArgConverterFunc = jsonEncode   // converts values to a JSON encoded string (nil values are not converted)
+	EnumStringConverter   ArgConverterFunc = enumString   // converts values that implement fmt.Stringer to string (e.g. enums)
+)
+
+func nullableZero(v any) (any, error) {
+	if v != nil {
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Map:
+			if rv.Len() == 0 {
+				return nil, nil
+			}
+		default:
+			if rv.IsZero() {
+				return nil, nil
+			}
+		}
+	}
+	return v, nil
+}
+
+func trimString(v any) (any, error) {
+	return convertString(v, strings.TrimSpace), nil
+}
+
+func lowerString(v any) (any, error) {
+	return convertString(v, strings.ToLower), nil
+}
+
+func convertString(v any, fn func(string) string) any {
+	switch vt := v.(type) {
+	case string:
+		return fn(vt)
+	case *string:
+		if vt != nil {
+			return fn(*vt)
+		}
+	}
+	return v
+}
+
+func jsonEncode(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func enumString(v any) (any, error) {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return v, nil
+}
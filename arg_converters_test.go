@@ -0,0 +1,125 @@
+package sqlnt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type testEnum int
+
+func (e testEnum) String() string {
+	return [...]string{"ACTIVE", "INACTIVE"}[e]
+}
+
+func TestArgConverters(t *testing.T) {
+	zero := 0
+	one := 1
+	str := "  Foo  "
+	var nilStr *string
+	testCases := []struct {
+		converter   ArgConverterFunc
+		value       any
+		expect      any
+		expectError string
+	}{
+		{converter: NullableZeroConverter, value: nil, expect: nil},
+		{converter: NullableZeroConverter, value: 0, expect: nil},
+		{converter: NullableZeroConverter, value: 1, expect: 1},
+		{converter: NullableZeroConverter, value: 0.0, expect: nil},
+		{converter: NullableZeroConverter, value: "", expect: nil},
+		{converter: NullableZeroConverter, value: "a", expect: "a"},
+		{converter: NullableZeroConverter, value: false, expect: nil},
+		{converter: NullableZeroConverter, value: time.Time{}, expect: nil},
+		{converter: NullableZeroConverter, value: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), expect: time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{converter: NullableZeroConverter, value: []int{}, expect: nil},
+		{converter: NullableZeroConverter, value: []int{0}, expect: []int{0}},
+		{converter: NullableZeroConverter, value: map[string]any{}, expect: nil},
+		{converter: NullableZeroConverter, value: &zero, expect: nil},
+		{converter: NullableZeroConverter, value: &one, expect: &one},
+		{converter: NullableZeroConverter, value: nilStr, expect: nil},
+		{converter: TrimConverter, value: "  Foo  ", expect: "Foo"},
+		{converter: TrimConverter, value: &str, expect: "Foo"},
+		{converter: TrimConverter, value: nilStr, expect: nilStr},
+		{converter: TrimConverter, value: 1, expect: 1},
+		{converter: LowerCaseConverter, value: "Foo", expect: "foo"},
+		{converter: LowerCaseConverter, value: 1, expect: 1},
+		{converter: JsonConverter, value: nil, expect: nil},
+		{converter: JsonConverter, value: map[string]any{"a": 1}, expect: `{"a":1}`},
+		{converter: JsonConverter, value: []string{"a"}, expect: `["a"]`},
+		{converter: JsonConverter, value: func() {}, expectError: "json: unsupported type: func()"},
+		{converter: EnumStringConverter, value: testEnum(1), expect: "INACTIVE"},
+		{converter: EnumStringConverter, value: 1, expect: 1},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]", i+1), func(t *testing.T) {
+			r, err := tc.converter(tc.value)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expect, r)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_ArgConverter(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table (name,email,status,data,count) VALUES (:name, :email, :status:string, :data, :count)`, PostgresOption).
+		ArgConverter("name", TrimConverter, NullableZeroConverter).
+		ArgConverter("email", TrimConverter).
+		ArgConverter("email", LowerCaseConverter).
+		ArgConverter("status", EnumStringConverter).
+		ArgConverter("data", JsonConverter).
+		ArgConverter("count", NullableZeroConverter).
+		ArgConverter("unknown", TrimConverter)
+	args, err := nt.Args(map[string]any{
+		"name":   "   ",
+		"email":  " Foo@Example.com ",
+		"status": testEnum(0),
+		"data":   map[string]any{"a": 1},
+		"count":  0,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []any{nil, "foo@example.com", "ACTIVE", `{"a":1}`, nil}, args)
+
+	info := nt.GetArgsInfo()
+	assert.Equal(t, 2, len(info["name"].Converters))
+	assert.Equal(t, 2, len(info["email"].Converters))
+	assert.Equal(t, 1, len(info["status"].Converters))
+
+	// converters also apply to default values...
+	nt = nt.DefaultValue("count", 0)
+	args, err = nt.Args(map[string]any{"name": "a", "email": "b", "status": "c", "data": nil})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "b", "c", nil, nil}, args)
+
+	// converters are preserved by clone...
+	nt2 := nt.Clone(MySqlOption)
+	assert.Equal(t, 2, len(nt2.GetArgsInfo()["email"].Converters))
+	args, err = nt2.Args(map[string]any{"name": "a", "email": " B ", "status": "c", "data": nil})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", "b", "c", nil, nil}, args)
+
+	nt = nt.ArgConverter("name", func(v any) (any, error) {
+		return nil, errors.New("fooey")
+	})
+	_, err = nt.Args(map[string]any{"name": "a", "email": "b", "status": "c", "data": nil})
+	assert.Error(t, err)
+	assert.Equal(t, "named arg 'name' converter failed: fooey", err.Error())
+	assert.Equal(t, 2, len(nt2.GetArgsInfo()["name"].Converters))
+}
+
+func TestNamedTemplate_ArgConverter_TypeApplied(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE status = :status:string`).
+		ArgConverter("status", func(v any) (any, error) {
+			return 1, nil
+		})
+	_, err := nt.Args(map[string]any{"status": "a"})
+	assert.Error(t, err)
+	assert.Equal(t, "named arg 'status' cannot be converted to string (from int)", err.Error())
+}
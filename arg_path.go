@@ -0,0 +1,65 @@
+package sqlnt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// lookupArg looks up the value for a named arg in the supplied mapped args
+//
+// Dotted names (e.g. "user.address.city") that are not directly supplied are resolved by walking
+// nested maps, structs and pointers - if a path segment cannot be found, the reason is returned (as missing)
+//
+// Returns an error if a path segment cannot be walked (i.e. is not a map or struct)
+func lookupArg(name string, mapped map[string]any) (v any, found bool, missing string, err error) {
+	if v, found = mapped[name]; found || !strings.ContainsRune(name, '.') {
+		return
+	}
+	segments := strings.Split(name, ".")
+	if v, found = mapped[segments[0]]; !found {
+		return
+	}
+	for i := 1; i < len(segments); i++ {
+		path := strings.Join(segments[:i], ".")
+		rv := reflect.ValueOf(v)
+		for (rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface) && !rv.IsNil() {
+			rv = rv.Elem()
+		}
+		switch rv.Kind() {
+		case reflect.Invalid, reflect.Pointer, reflect.Interface:
+			return nil, false, fmt.Sprintf("'%s' is nil", path), nil
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				return nil, false, "", newArgsError("named arg '%s' cannot be resolved - '%s' is not a map with string keys", name, path)
+			}
+			mv := rv.MapIndex(reflect.ValueOf(segments[i]).Convert(rv.Type().Key()))
+			if !mv.IsValid() {
+				return nil, false, fmt.Sprintf("'%s' not found in '%s'", segments[i], path), nil
+			}
+			v = mv.Interface()
+		case reflect.Struct:
+			fv, ok := structField(rv, segments[i])
+			if !ok {
+				return nil, false, fmt.Sprintf("'%s' not found in '%s'", segments[i], path), nil
+			}
+			v = fv
+		default:
+			return nil, false, "", newArgsError("named arg '%s' cannot be resolved - '%s' is not a map or struct", name, path)
+		}
+	}
+	return v, true, "", nil
+}
+
+// structField returns the value of the mapped struct field with the given name
+func structField(rv reflect.Value, name string) (any, bool) {
+	for _, f := range getStructMapping(rv.Type()).fields {
+		if f.name == name {
+			if fv, err := rv.FieldByIndexErr(f.index); err == nil {
+				return fieldValue(fv), true
+			}
+			break
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,154 @@
+package sqlnt
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// argConverter converts a supplied value to the annotated arg type - returning false if it cannot be converted
+type argConverter func(v reflect.Value) (any, bool)
+
+var argTypes = map[string]argConverter{
+	"int":    toInt,
+	"float":  toFloat,
+	"string": toString,
+	"bool":   toBool,
+	"time":   toTime,
+}
+
+var argSliceTypes = map[string]reflect.Type{
+	"int":    reflect.TypeOf(int64(0)),
+	"float":  reflect.TypeOf(float64(0)),
+	"string": reflect.TypeOf(""),
+	"bool":   reflect.TypeOf(false),
+	"time":   timeType,
+}
+
+// isArgType determines whether the annotated type (e.g. "int" or "[]int") is known
+func isArgType(typ string) bool {
+	_, ok := argTypes[strings.TrimPrefix(typ, "[]")]
+	return ok
+}
+
+// convertArg converts the supplied value to the annotated type
+//
+// nil values and driver.Valuer values are not converted
+func convertArg(name string, typ string, v any) (any, error) {
+	if v == nil || typ == "" {
+		return v, nil
+	} else if _, ok := v.(driver.Valuer); ok {
+		return v, nil
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	var r any
+	ok := false
+	if strings.HasPrefix(typ, "[]") {
+		r, ok = toSlice(rv, typ[2:])
+	} else {
+		r, ok = argTypes[typ](rv)
+	}
+	if !ok {
+		return nil, newArgsError("named arg '%s' cannot be converted to %s (from %T)", name, typ, v)
+	}
+	return r, nil
+}
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+func toInt(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		}
+	case reflect.Float32, reflect.Float64:
+		// e.g. numbers unmarshalled from json are float64...
+		if f := v.Float(); f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), true
+		}
+	case reflect.String:
+		if v.Type() == jsonNumberType {
+			i, err := json.Number(v.String()).Int64()
+			return i, err == nil
+		}
+	}
+	return nil, false
+}
+
+func toFloat(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		if v.Type() == jsonNumberType {
+			f, err := json.Number(v.String()).Float64()
+			return f, err == nil
+		}
+	}
+	return nil, false
+}
+
+func toString(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.String {
+		return v.String(), true
+	}
+	return nil, false
+}
+
+func toBool(v reflect.Value) (any, bool) {
+	if v.Kind() == reflect.Bool {
+		return v.Bool(), true
+	}
+	return nil, false
+}
+
+func toTime(v reflect.Value) (any, bool) {
+	if v.Type() == timeType {
+		return v.Interface(), true
+	} else if v.Kind() == reflect.String {
+		// e.g. times unmarshalled from json are RFC3339 strings...
+		t, err := time.Parse(time.RFC3339Nano, v.String())
+		return t, err == nil
+	}
+	return nil, false
+}
+
+func toSlice(v reflect.Value, base string) (any, bool) {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	converter := argTypes[base]
+	l := v.Len()
+	r := reflect.MakeSlice(reflect.SliceOf(argSliceTypes[base]), l, l)
+	for i := 0; i < l; i++ {
+		iv := v.Index(i)
+		for iv.Kind() == reflect.Interface || iv.Kind() == reflect.Pointer {
+			if iv.IsNil() {
+				return nil, false
+			}
+			iv = iv.Elem()
+		}
+		cv, ok := converter(iv)
+		if !ok {
+			return nil, false
+		}
+		r.Index(i).Set(reflect.ValueOf(cv))
+	}
+	return r.Interface(), true
+}
@@ -0,0 +1,84 @@
+package sqlnt
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConvertArg(t *testing.T) {
+	i := 42
+	var nilPtr *int
+	tm := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	testCases := []struct {
+		typ         string
+		value       any
+		expect      any
+		expectError bool
+	}{
+		{typ: "", value: "abc", expect: "abc"},
+		{typ: "int", value: nil, expect: nil},
+		{typ: "int", value: nilPtr, expect: nil},
+		{typ: "int", value: &i, expect: int64(42)},
+		{typ: "int", value: 42, expect: int64(42)},
+		{typ: "int", value: int8(42), expect: int64(42)},
+		{typ: "int", value: uint16(42), expect: int64(42)},
+		{typ: "int", value: uint64(math.MaxUint64), expectError: true},
+		{typ: "int", value: float64(42), expect: int64(42)},
+		{typ: "int", value: 42.5, expectError: true},
+		{typ: "int", value: json.Number("42"), expect: int64(42)},
+		{typ: "int", value: json.Number("42.5"), expectError: true},
+		{typ: "int", value: "42", expectError: true},
+		{typ: "int", value: sql.NullInt64{Int64: 42, Valid: true}, expect: sql.NullInt64{Int64: 42, Valid: true}},
+		{typ: "float", value: 42, expect: float64(42)},
+		{typ: "float", value: uint(42), expect: float64(42)},
+		{typ: "float", value: float32(1.5), expect: 1.5},
+		{typ: "float", value: json.Number("1.5"), expect: 1.5},
+		{typ: "float", value: json.Number("x"), expectError: true},
+		{typ: "float", value: "1.5", expectError: true},
+		{typ: "string", value: "abc", expect: "abc"},
+		{typ: "string", value: 42, expectError: true},
+		{typ: "bool", value: true, expect: true},
+		{typ: "bool", value: "true", expectError: true},
+		{typ: "time", value: tm, expect: tm},
+		{typ: "time", value: &tm, expect: tm},
+		{typ: "time", value: "2023-01-02T03:04:05Z", expect: tm},
+		{typ: "time", value: "2023-01-02", expectError: true},
+		{typ: "time", value: 42, expectError: true},
+		{typ: "[]int", value: []any{1, float64(2), json.Number("3")}, expect: []int64{1, 2, 3}},
+		{typ: "[]int", value: [2]int{1, 2}, expect: []int64{1, 2}},
+		{typ: "[]int", value: []*int{&i}, expect: []int64{42}},
+		{typ: "[]int", value: []any{1, nil}, expectError: true},
+		{typ: "[]int", value: []any{1, "2"}, expectError: true},
+		{typ: "[]int", value: 1, expectError: true},
+		{typ: "[]string", value: []string{}, expect: []string{}},
+		{typ: "[]float", value: []int{1}, expect: []float64{1}},
+		{typ: "[]bool", value: []bool{true}, expect: []bool{true}},
+		{typ: "[]time", value: []string{"2023-01-02T03:04:05Z"}, expect: []time.Time{tm}},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.typ), func(t *testing.T) {
+			r, err := convertArg("a", tc.typ, tc.value)
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Equal(t, fmt.Sprintf("named arg 'a' cannot be converted to %s (from %T)", tc.typ, tc.value), err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expect, r)
+			}
+		})
+	}
+}
+
+func TestIsArgType(t *testing.T) {
+	for _, typ := range []string{"int", "float", "string", "bool", "time", "[]int", "[]float", "[]string", "[]bool", "[]time"} {
+		assert.True(t, isArgType(typ))
+	}
+	for _, typ := range []string{"", "[]", "integer", "[][]int", "int[]", "uuid"} {
+		assert.False(t, isArgType(typ))
+	}
+}
@@ -0,0 +1,282 @@
+package sqlnt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrInvalidArgs is matched (using errors.Is) by errors caused by the supplied args - e.g. MissingArgsError,
+	// UnusedArgsError, ErrInvalidMap, values that cannot be converted and disallowed runtime token values (i.e. client input errors)
+	ErrInvalidArgs = errors.New("invalid args")
+	// ErrInvalidTemplate is matched (using errors.Is) by errors caused by the template statement - e.g. ParseError,
+	// UnknownTokensError and ConflictingArgsError (i.e. programming errors)
+	ErrInvalidTemplate = errors.New("invalid template")
+	// ErrInvalidMap is the error returned when a supplied map arg has non-string keys
+	ErrInvalidMap error = &argsError{msg: "invalid map - keys must be string"}
+)
+
+// argsError is a plain error caused by the supplied args
+type argsError struct {
+	msg string
+	err error
+}
+
+func newArgsError(format string, a ...any) error {
+	return &argsError{msg: fmt.Sprintf(format, a...)}
+}
+
+func (e *argsError) Error() string {
+	return e.msg
+}
+
+func (e *argsError) Unwrap() error {
+	return e.err
+}
+
+func (e *argsError) Is(target error) bool {
+	return target == ErrInvalidArgs
+}
+
+// MissingArgsError is the error returned when named args are missing from the supplied args
+type MissingArgsError struct {
+	// Names is the names of the missing named args (in statement order)
+	Names []string
+	// Reasons is the reason (if known) for each missing named arg - e.g. for dotted arg names, the part of the path that was missing
+	Reasons map[string]string
+}
+
+func (e *MissingArgsError) Error() string {
+	if len(e.Names) == 1 {
+		if reason := e.Reasons[e.Names[0]]; reason != "" {
+			return fmt.Sprintf("named arg '%s' missing (%s)", e.Names[0], reason)
+		}
+		return fmt.Sprintf("named arg '%s' missing", e.Names[0])
+	}
+	missing := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		missing[i] = "'" + name + "'"
+		if reason := e.Reasons[name]; reason != "" {
+			missing[i] += " (" + reason + ")"
+		}
+	}
+	return "named args missing: " + strings.Join(missing, ", ")
+}
+
+func (e *MissingArgsError) Is(target error) bool {
+	return target == ErrInvalidArgs
+}
+
+// add adds the missing arg(s) from another error - returning false if the error is not a MissingArgsError
+func (e *MissingArgsError) add(err error) bool {
+	var other *MissingArgsError
+	if !errors.As(err, &other) {
+		return false
+	}
+	for _, name := range other.Names {
+		if _, ok := e.Reasons[name]; !ok {
+			e.Names = append(e.Names, name)
+			e.Reasons[name] = other.Reasons[name]
+		}
+	}
+	return true
+}
+
+func newMissingArgsError() *MissingArgsError {
+	return &MissingArgsError{
+		Names:   make([]string, 0),
+		Reasons: map[string]string{},
+	}
+}
+
+// errorOrNil returns the error (or nil if there are no missing args)
+func (e *MissingArgsError) errorOrNil() error {
+	if len(e.Names) == 0 {
+		return nil
+	}
+	return e
+}
+
+// UnusedArgsError is the error returned when supplied args are not used by the template (see UnknownArgsBehaviour)
+type UnusedArgsError struct {
+	// Names is the names of the unused supplied args (sorted)
+	Names []string
+}
+
+func (e *UnusedArgsError) Error() string {
+	if len(e.Names) == 1 {
+		return "unknown arg: " + e.Names[0]
+	}
+	return "unknown args: " + strings.Join(e.Names, ", ")
+}
+
+func (e *UnusedArgsError) Is(target error) bool {
+	return target == ErrInvalidArgs
+}
+
+// ConflictingArgsError is the error returned when composing templates (see Concat) where the same named arg
+// has conflicting options (e.g. different default values) in different templates
+type ConflictingArgsError struct {
+	// Names is the names of the named args with conflicting options (sorted)
+	Names []string
+	// Options is the names of the conflicting options (e.g. "default value", "converters" or "omissible") for each named arg
+	Options map[string][]string
+}
+
+func (e *ConflictingArgsError) Error() string {
+	if len(e.Names) == 1 {
+		return fmt.Sprintf("named arg '%s' has conflicting options: %s", e.Names[0], strings.Join(e.Options[e.Names[0]], ", "))
+	}
+	conflicts := make([]string, len(e.Names))
+	for i, name := range e.Names {
+		conflicts[i] = "'" + name + "' (" + strings.Join(e.Options[name], ", ") + ")"
+	}
+	return "named args have conflicting options: " + strings.Join(conflicts, ", ")
+}
+
+func (e *ConflictingArgsError) Is(target error) bool {
+	return target == ErrInvalidTemplate
+}
+
+// TemplateError is the error returned by a named template (see NamedTemplate.Name and TemplateName) - wrapping
+// the underlying error (e.g. MissingArgsError) with the name of the template
+//
+// Use errors.Is or errors.As to match the underlying error
+type TemplateError struct {
+	// Template is the name of the template
+	Template string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	return "template '" + e.Template + "': " + e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Location is the line and column (both 1 based) in a template statement
+type Location struct {
+	Line   int
+	Column int
+}
+
+func (l Location) String() string {
+	return fmt.Sprintf("line %d, column %d", l.Line, l.Column)
+}
+
+// locationOf returns the location immediately following the supplied statement prefix
+func locationOf(prefix string) Location {
+	line := strings.Count(prefix, "\n")
+	if i := strings.LastIndexByte(prefix, '\n'); i != -1 {
+		prefix = prefix[i+1:]
+	}
+	return Location{
+		Line:   line + 1,
+		Column: utf8.RuneCountInString(prefix) + 1,
+	}
+}
+
+// ParseError is the error returned when a template statement cannot be parsed
+type ParseError struct {
+	// Message is the description of the parse error
+	Message string
+	// Position is the rune position in the statement at which the parse error occurred
+	Position int
+	// Location is the line and column in the statement at which the parse error occurred
+	Location
+	// Snippet is the (possibly truncated) line of the statement at which the parse error occurred
+	Snippet string
+	// Field is the name of the field (for templates created by NewTemplateSet)
+	Field string
+}
+
+func newParseError(pos int, format string, a ...any) *ParseError {
+	return &ParseError{
+		Message:  fmt.Sprintf(format, a...),
+		Position: pos,
+	}
+}
+
+// maxSnippet is the max length (in runes) of a ParseError.Snippet
+const maxSnippet = 60
+
+// locate sets the location and snippet of the parse error in the statement
+func (e *ParseError) locate(statement string) {
+	runes := []rune(statement)
+	pos := e.Position
+	if pos > len(runes) {
+		pos = len(runes)
+	}
+	e.Location = locationOf(string(runes[:pos]))
+	start := pos - (e.Column - 1)
+	end := pos
+	for ; end < len(runes) && runes[end] != '\n'; end++ {
+	}
+	line := runes[start:end]
+	if len(line) > maxSnippet {
+		from := e.Column - 1 - maxSnippet/2
+		if from < 0 {
+			from = 0
+		} else if from > len(line)-maxSnippet {
+			from = len(line) - maxSnippet
+		}
+		snippet := string(line[from : from+maxSnippet])
+		if from > 0 {
+			snippet = "..." + snippet
+		}
+		if from+maxSnippet < len(line) {
+			snippet += "..."
+		}
+		e.Snippet = snippet
+	} else {
+		e.Snippet = string(line)
+	}
+}
+
+func (e *ParseError) Error() string {
+	return fieldPrefix(e.Field) + fmt.Sprintf("%s (at %s: %q)", e.Message, e.Location, e.Snippet)
+}
+
+func (e *ParseError) Is(target error) bool {
+	return target == ErrInvalidTemplate
+}
+
+// UnknownTokensError is the error returned when tokens in a template statement are not replaced by any TokenOption
+type UnknownTokensError struct {
+	// Tokens is the unknown tokens (in statement order)
+	Tokens []string
+	// Locations is the line and column of each unknown token
+	Locations []Location
+	// Field is the name of the field (for templates created by NewTemplateSet)
+	Field string
+}
+
+func (e *UnknownTokensError) Error() string {
+	tokens := make([]string, len(e.Tokens))
+	for i, token := range e.Tokens {
+		tokens[i] = token
+		if i < len(e.Locations) {
+			tokens[i] += " (at " + e.Locations[i].String() + ")"
+		}
+	}
+	if len(tokens) == 1 {
+		return fieldPrefix(e.Field) + "unknown token: " + tokens[0]
+	}
+	return fieldPrefix(e.Field) + "unknown tokens: " + strings.Join(tokens, ", ")
+}
+
+func (e *UnknownTokensError) Is(target error) bool {
+	return target == ErrInvalidTemplate
+}
+
+func fieldPrefix(field string) string {
+	if field != "" {
+		return "field '" + field + "': "
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package sqlnt
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMissingArgsError(t *testing.T) {
+	testCases := []struct {
+		statement   string
+		options     []any
+		expectNames []string
+		expectError string
+	}{
+		{
+			statement:   `SELECT * FROM table WHERE col_c = :c AND col_a = :a AND col_b = :b AND col_d = :d? AND col_c2 = :c`,
+			expectNames: []string{"c", "a", "b"},
+			expectError: "named args missing: 'c', 'a', 'b'",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_c = :c AND col_a = :a AND col_b = :b AND col_d = :d? AND col_c2 = :c`,
+			options:     []any{PostgresOption},
+			expectNames: []string{"c", "a", "b"},
+			expectError: "named args missing: 'c', 'a', 'b'",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_c IN (:c...) AND col_a = :a AND col_b = :b AND col_c2 IN (:c...)`,
+			options:     []any{PostgresOption},
+			expectNames: []string{"c", "a", "b"},
+			expectError: "named args missing: 'c', 'a', 'b'",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :a AND col_b = :user.name`,
+			expectNames: []string{"a", "user.name"},
+			expectError: "named args missing: 'a', 'user.name' ('user' is nil)",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :a`,
+			expectNames: []string{"a"},
+			expectError: "named arg 'a' missing",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.statement, func(t *testing.T) {
+			nt := MustCreateNamedTemplate(tc.statement, tc.options...)
+			for i := 0; i < 10; i++ {
+				_, err := nt.Args(map[string]any{"user": nil})
+				require.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+				var missing *MissingArgsError
+				require.True(t, errors.As(err, &missing))
+				assert.Equal(t, tc.expectNames, missing.Names)
+				assert.ErrorIs(t, err, ErrInvalidArgs)
+				assert.NotErrorIs(t, err, ErrInvalidTemplate)
+			}
+		})
+	}
+}
+
+func TestMissingArgsError_Batches(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table (col_a,col_b,col_c) VALUES (:a, :b, :c)...`)
+	_, err := nt.Batches([]map[string]any{{"a": 1, "b": 2, "c": 3}, {"b": 2}})
+	require.Error(t, err)
+	assert.Equal(t, "row 1: named args missing: 'a', 'c'", err.Error())
+	var missing *MissingArgsError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, []string{"a", "c"}, missing.Names)
+}
+
+func TestParseError(t *testing.T) {
+	_, err := NewNamedTemplate(`SELECT * FROM table WHERE col_a = : AND col_b = :b`)
+	require.Error(t, err)
+	assert.Equal(t, `named marker ':' without name (at line 1, column 35: "SELECT * FROM table WHERE col_a = : AND col_b = :b")`, err.Error())
+	var perr *ParseError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 34, perr.Position)
+	assert.Equal(t, "named marker ':' without name", perr.Message)
+	assert.ErrorIs(t, err, ErrInvalidTemplate)
+	assert.NotErrorIs(t, err, ErrInvalidArgs)
+
+	_, err = NewNamedTemplate(`SELECT * FROM table WHERE col_a = 'unterminated`)
+	assert.ErrorAs(t, err, &perr)
+	_, err = NewNamedTemplate(`SELECT * FROM table WHERE col_a = :a:int AND col_b = :a:time`)
+	assert.ErrorAs(t, err, &perr)
+}
+
+func TestUnknownTokensError(t *testing.T) {
+	_, err := NewNamedTemplate(`SELECT * FROM {{table}} WHERE {{where}}`)
+	require.Error(t, err)
+	assert.Equal(t, "unknown tokens: table (at line 1, column 15), where (at line 1, column 31)", err.Error())
+	var terr *UnknownTokensError
+	require.True(t, errors.As(err, &terr))
+	assert.Equal(t, []string{"table", "where"}, terr.Tokens)
+	assert.ErrorIs(t, err, ErrInvalidTemplate)
+
+	_, err = NewTemplateSet[BadSet2](testTokenOption)
+	assert.ErrorAs(t, err, &terr)
+}
+
+func TestInvalidArgsErrors(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a:int AND col_b = :b.c`, UnknownArgsError).
+		ArgConverter("a", func(v any) (any, error) {
+			if v == "fail" {
+				return nil, errors.New("fooey")
+			}
+			return v, nil
+		})
+
+	_, err := nt.Args(map[int]any{1: "a"})
+	assert.ErrorIs(t, err, ErrInvalidMap)
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+	assert.Equal(t, "invalid map - keys must be string", err.Error())
+
+	_, err = nt.Args(map[string]any{"a": 1, "b": map[string]any{"c": 1}, "x": 1})
+	var uerr *UnusedArgsError
+	assert.ErrorAs(t, err, &uerr)
+	assert.Equal(t, []string{"x"}, uerr.Names)
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+
+	_, err = nt.Args(map[string]any{"a": "x", "b": map[string]any{"c": 1}})
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+
+	_, err = nt.Args(map[string]any{"a": 1, "b": 1})
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+
+	_, err = nt.Args(map[string]any{"a": "fail", "b": map[string]any{"c": 1}})
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+	assert.Equal(t, "named arg 'a' converter failed: fooey", err.Error())
+	assert.Equal(t, "fooey", errors.Unwrap(err).Error())
+
+	_, err = MustCreateNamedTemplate(`SELECT * FROM {{table}}`, IdentifierToken("table")).Args(TokenValues{"table": "1"})
+	assert.ErrorIs(t, err, ErrInvalidArgs)
+}
+
+func TestParseError_Location(t *testing.T) {
+	_, err := NewNamedTemplate("SELECT *\nFROM table\nWHERE col_a = : AND col_b = :b")
+	require.Error(t, err)
+	assert.Equal(t, `named marker ':' without name (at line 3, column 15: "WHERE col_a = : AND col_b = :b")`, err.Error())
+	var perr *ParseError
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 34, perr.Position)
+	assert.Equal(t, 3, perr.Line)
+	assert.Equal(t, 15, perr.Column)
+	assert.Equal(t, "WHERE col_a = : AND col_b = :b", perr.Snippet)
+	assert.Equal(t, "", perr.Field)
+
+	_, err = NewNamedTemplate("SELECT *\r\nFROM table\r\nWHERE col_a = 'unterminated\r\nAND col_b = :b")
+	require.True(t, errors.As(err, &perr))
+	assert.Equal(t, 3, perr.Line)
+	assert.Equal(t, 15, perr.Column)
+	assert.Equal(t, "WHERE col_a = 'unterminated\r", perr.Snippet)
+}
+
+func TestParseError_Snippet(t *testing.T) {
+	long := "SELECT col_a, col_b, col_c, col_d, col_e, col_f, col_g, col_h, col_i, col_j"
+	testCases := []struct {
+		statement     string
+		expectSnippet string
+		expectColumn  int
+	}{
+		{
+			statement:     long + " FROM table WHERE col_a = : AND col_b = :b",
+			expectSnippet: "...ol_h, col_i, col_j FROM table WHERE col_a = : AND col_b = :b",
+			expectColumn:  102,
+		},
+		{
+			statement:     "SELECT : " + long,
+			expectSnippet: "SELECT : SELECT col_a, col_b, col_c, col_d, col_e, col_f, co...",
+			expectColumn:  8,
+		},
+		{
+			statement:     long + " WHERE col_a = : AND " + long,
+			expectSnippet: "...h, col_i, col_j WHERE col_a = : AND SELECT col_a, col_b, col...",
+			expectColumn:  91,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.statement, func(t *testing.T) {
+			_, err := NewNamedTemplate(tc.statement)
+			var perr *ParseError
+			require.True(t, errors.As(err, &perr))
+			assert.Equal(t, tc.expectSnippet, perr.Snippet)
+			assert.Equal(t, tc.expectColumn, perr.Column)
+		})
+	}
+}
+
+func TestUnknownTokensError_Location(t *testing.T) {
+	_, err := NewNamedTemplate("SELECT *\nFROM {{table}}\nWHERE {{where}} AND {{€}}")
+	require.Error(t, err)
+	assert.Equal(t, "unknown tokens: table (at line 2, column 6), where (at line 3, column 7), € (at line 3, column 21)", err.Error())
+	var terr *UnknownTokensError
+	require.True(t, errors.As(err, &terr))
+	assert.Equal(t, []Location{{Line: 2, Column: 6}, {Line: 3, Column: 7}, {Line: 3, Column: 21}}, terr.Locations)
+}
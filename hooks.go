@@ -0,0 +1,173 @@
+package sqlnt
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Operation is the kind of db operation being performed (as reported to hooks in HookEvent.Operation)
+type Operation string
+
+const (
+	OpExec      Operation = "Exec"      // NamedTemplate.Exec / ExecContext or NamedStatement.Exec / ExecContext
+	OpQuery     Operation = "Query"     // NamedTemplate.Query / QueryContext or NamedStatement.Query / QueryContext
+	OpQueryRow  Operation = "QueryRow"  // NamedTemplate.QueryRow / QueryRowContext or NamedStatement.QueryRow / QueryRowContext
+	OpExecBatch Operation = "ExecBatch" // each batch of NamedTemplate.ExecBatch / ExecBatchContext
+)
+
+// HookEvent is the execution of a template statement - as passed to Hook.Before and Hook.After
+type HookEvent struct {
+	// Template is the template being executed
+	Template NamedTemplate
+	// Operation is the kind of db operation being performed
+	Operation Operation
+	// Prepared denotes whether the statement is being executed via a prepared NamedStatement
+	Prepared bool
+	// Statement is the sql statement to be executed
+	//
+	// Hook.Before may change the statement (e.g. to add a comment) - except where Prepared is true
+	Statement string
+	// Args is the positional args for the statement
+	//
+	// Hook.Before may change the args
+	Args []any
+	// Result is the result of an OpExec or OpExecBatch (set for Hook.After)
+	Result sql.Result
+	// Rows is the result of an OpQuery (set for Hook.After)
+	Rows *sql.Rows
+	// Row is the result of an OpQueryRow (set for Hook.After)
+	Row *sql.Row
+	// Err is the error from the db operation or from a Hook.Before (set for Hook.After)
+	Err error
+	// Duration is the time taken by the db operation (set for Hook.After)
+	//
+	// NB. For OpQuery, this is the time until the rows are returned (not the time taken to read them)
+	Duration time.Duration
+}
+
+// Hook is the interface for observing (and intercepting) the execution of templates by NamedTemplate.Exec,
+// NamedTemplate.Query, NamedTemplate.QueryRow, NamedTemplate.ExecBatch (and their context variants) and by NamedStatement
+//
+// Hooks can be registered globally (see AddGlobalHooks), passed as an option to NewNamedTemplate or NewTemplateSet, or
+// added to a template using NamedTemplate.WithHooks
+//
+// Hook.Before is called for each hook in order (global hooks first) - and Hook.After is called in reverse order.
+// Hooks are only called once the supplied named args have been successfully converted (errors converting the args
+// are returned without calling any hooks)
+type Hook interface {
+	// Before is called before the db operation is performed
+	//
+	// The returned context is used for the db operation (and subsequent hooks) - if nil, the context is unchanged
+	//
+	// Returning an error short-circuits the execution - the db operation is not performed, subsequent hooks are not
+	// called and the error is returned to the caller (Hook.After is still called for hooks whose Before has already been called)
+	Before(ctx context.Context, event *HookEvent) (context.Context, error)
+	// After is called after the db operation has been performed (or short-circuited)
+	After(ctx context.Context, event *HookEvent)
+}
+
+// HookFuncs is a Hook implemented by funcs (either of which can be nil)
+type HookFuncs struct {
+	BeforeFunc func(ctx context.Context, event *HookEvent) (context.Context, error)
+	AfterFunc  func(ctx context.Context, event *HookEvent)
+}
+
+func (h HookFuncs) Before(ctx context.Context, event *HookEvent) (context.Context, error) {
+	if h.BeforeFunc != nil {
+		return h.BeforeFunc(ctx, event)
+	}
+	return ctx, nil
+}
+
+func (h HookFuncs) After(ctx context.Context, event *HookEvent) {
+	if h.AfterFunc != nil {
+		h.AfterFunc(ctx, event)
+	}
+}
+
+type templateNameKey struct{}
+
+// TemplateNameFromContext returns the name of the template (see NamedTemplate.Name) being executed - for use by hooks and
+// by driver wrappers (which receive the context of the db operation)
+//
+// Returns false if the context is not from the execution of a named template
+func TemplateNameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(templateNameKey{}).(string)
+	return name, ok
+}
+
+var globalHooks = struct {
+	sync.RWMutex
+	hooks []Hook
+}{}
+
+// AddGlobalHooks registers hooks that are called for the execution of all templates (before any template hooks)
+func AddGlobalHooks(hooks ...Hook) {
+	globalHooks.Lock()
+	defer globalHooks.Unlock()
+	globalHooks.hooks = append(append([]Hook{}, globalHooks.hooks...), hooks...)
+}
+
+// ClearGlobalHooks removes all hooks registered by AddGlobalHooks
+func ClearGlobalHooks() {
+	globalHooks.Lock()
+	defer globalHooks.Unlock()
+	globalHooks.hooks = nil
+}
+
+// WithHooks returns a copy of the template with the additional hooks
+func (n *namedTemplate) WithHooks(hooks ...Hook) NamedTemplate {
+	r := n.copy()
+	r.hooks = append(append([]Hook{}, n.hooks...), hooks...)
+	return r
+}
+
+func (n *namedTemplate) newHookEvent(op Operation, statement string, args []any) *HookEvent {
+	return &HookEvent{
+		Template:  n,
+		Operation: op,
+		Statement: statement,
+		Args:      args,
+	}
+}
+
+// execute performs the db operation (fn) for the event - calling the global and template hooks around it
+//
+// The context for the db operation (and hooks) carries the name of the template (see TemplateNameFromContext)
+func (n *namedTemplate) execute(ctx context.Context, event *HookEvent, fn func(ctx context.Context) error) error {
+	if n.name != "" {
+		ctx = context.WithValue(ctx, templateNameKey{}, n.name)
+	}
+	globalHooks.RLock()
+	hooks := globalHooks.hooks
+	globalHooks.RUnlock()
+	if len(n.hooks) > 0 {
+		hooks = append(append(make([]Hook, 0, len(hooks)+len(n.hooks)), hooks...), n.hooks...)
+	}
+	if len(hooks) == 0 {
+		return fn(ctx)
+	}
+	var err error
+	called := 0
+	for _, h := range hooks {
+		var hctx context.Context
+		if hctx, err = h.Before(ctx, event); err != nil {
+			break
+		} else if hctx != nil {
+			ctx = hctx
+		}
+		called++
+	}
+	if err == nil {
+		start := time.Now()
+		err = fn(ctx)
+		event.Duration = time.Since(start)
+	}
+	event.Err = err
+	for i := called - 1; i >= 0; i-- {
+		hooks[i].After(ctx, event)
+	}
+	return err
+}
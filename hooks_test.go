@@ -0,0 +1,214 @@
+package sqlnt
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testHookCtxKey string
+
+type testHook struct {
+	name   string
+	calls  *[]string
+	events []*HookEvent
+	err    error
+}
+
+func (h *testHook) Before(ctx context.Context, event *HookEvent) (context.Context, error) {
+	*h.calls = append(*h.calls, "before:"+h.name)
+	if h.err != nil {
+		return nil, h.err
+	}
+	return context.WithValue(ctx, testHookCtxKey(h.name), true), nil
+}
+
+func (h *testHook) After(ctx context.Context, event *HookEvent) {
+	*h.calls = append(*h.calls, "after:"+h.name)
+	h.events = append(h.events, event)
+}
+
+func TestHooks(t *testing.T) {
+	calls := make([]string, 0)
+	global := &testHook{name: "global", calls: &calls}
+	AddGlobalHooks(global)
+	defer ClearGlobalHooks()
+	h1 := &testHook{name: "h1", calls: &calls}
+	h2 := &testHook{name: "h2", calls: &calls}
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, PostgresOption, h1).WithHooks(h2)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectExec(`SELECT * FROM table WHERE col_a = $1`).WithArgs("aa").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`SELECT * FROM table WHERE col_a = $1`).WithArgs("bb").WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	mock.ExpectQuery(`SELECT * FROM table WHERE col_a = $1`).WithArgs("cc").WillReturnError(errors.New("fooey"))
+
+	_, err = nt.Exec(db, map[string]any{"a": "aa"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before:global", "before:h1", "before:h2", "after:h2", "after:h1", "after:global"}, calls)
+	require.Equal(t, 1, len(h1.events))
+	event := h1.events[0]
+	assert.Equal(t, nt, event.Template)
+	assert.Equal(t, OpExec, event.Operation)
+	assert.False(t, event.Prepared)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1`, event.Statement)
+	assert.Equal(t, []any{"aa"}, event.Args)
+	assert.NotNil(t, event.Result)
+	assert.NoError(t, event.Err)
+	assert.True(t, event.Duration > 0)
+
+	rows, err := nt.Query(db, map[string]any{"a": "bb"})
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	event = h2.events[1]
+	assert.Equal(t, OpQuery, event.Operation)
+	assert.Equal(t, rows, event.Rows)
+
+	_, err = nt.QueryContext(context.Background(), db, map[string]any{"a": "cc"})
+	assert.Error(t, err)
+	event = global.events[2]
+	assert.Equal(t, err, event.Err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// hooks are not called when args cannot be converted...
+	calls = calls[:0]
+	_, err = nt.Exec(db, map[string]any{})
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(calls))
+}
+
+func TestHooks_ContextAndStatement(t *testing.T) {
+	var afterCtx context.Context
+	hook := HookFuncs{
+		BeforeFunc: func(ctx context.Context, event *HookEvent) (context.Context, error) {
+			event.Statement = "/* traced */ " + event.Statement
+			event.Args = append(event.Args, "extra")
+			return context.WithValue(ctx, testHookCtxKey("traced"), true), nil
+		},
+		AfterFunc: func(ctx context.Context, event *HookEvent) {
+			afterCtx = ctx
+		},
+	}
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, hook)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(`/* traced */ SELECT * FROM table WHERE col_a = ?`).WithArgs("aa", "extra").WillReturnRows(sqlmock.NewRows([]string{"col_a"}).AddRow("aa"))
+
+	row, err := nt.QueryRow(db, map[string]any{"a": "aa"})
+	require.NoError(t, err)
+	var a string
+	require.NoError(t, row.Scan(&a))
+	assert.Equal(t, "aa", a)
+	require.NotNil(t, afterCtx)
+	assert.Equal(t, true, afterCtx.Value(testHookCtxKey("traced")))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_ShortCircuit(t *testing.T) {
+	calls := make([]string, 0)
+	h1 := &testHook{name: "h1", calls: &calls}
+	h2 := &testHook{name: "h2", calls: &calls, err: errors.New("not allowed")}
+	h3 := &testHook{name: "h3", calls: &calls}
+	nt := MustCreateNamedTemplate(`DELETE FROM table WHERE col_a = :a`, h1, h2, h3)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	_, err = nt.Exec(db, map[string]any{"a": "aa"})
+	assert.Error(t, err)
+	assert.Equal(t, "not allowed", err.Error())
+	assert.Equal(t, []string{"before:h1", "before:h2", "after:h1"}, calls)
+	require.Equal(t, 1, len(h1.events))
+	assert.Equal(t, err, h1.events[0].Err)
+	assert.Nil(t, h1.events[0].Result)
+
+	_, err = nt.QueryRow(db, map[string]any{"a": "aa"})
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_PreparedAndBatch(t *testing.T) {
+	events := make([]*HookEvent, 0)
+	hook := HookFuncs{
+		AfterFunc: func(ctx context.Context, event *HookEvent) {
+			events = append(events, event)
+		},
+	}
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, hook)
+	prep := mock.ExpectPrepare(nt.Statement())
+	prep.ExpectExec().WithArgs("aa").WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectQuery().WithArgs("bb").WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	prep.ExpectQuery().WithArgs("cc").WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	stmt, err := nt.Prepare(context.Background(), db)
+	require.NoError(t, err)
+	_, err = stmt.Exec(map[string]any{"a": "aa"})
+	require.NoError(t, err)
+	rows, err := stmt.Query(map[string]any{"a": "bb"})
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	_, err = stmt.QueryRow(map[string]any{"a": "cc"})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(events))
+	assert.Equal(t, []Operation{OpExec, OpQuery, OpQueryRow}, []Operation{events[0].Operation, events[1].Operation, events[2].Operation})
+	assert.True(t, events[0].Prepared)
+	assert.Equal(t, nt.Statement(), events[0].Statement)
+
+	events = events[:0]
+	bt := MustCreateNamedTemplate(`INSERT INTO table (col_a) VALUES (:a)...`, &testMaxArgsOption{max: 2}, hook)
+	mock.ExpectExec(`INSERT INTO table (col_a) VALUES ($1), ($2)`).WithArgs("a1", "a2").WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec(`INSERT INTO table (col_a) VALUES ($1)`).WithArgs("a3").WillReturnResult(sqlmock.NewResult(3, 1))
+	_, err = bt.ExecBatch(db, []map[string]any{{"a": "a1"}, {"a": "a2"}, {"a": "a3"}})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(events))
+	assert.Equal(t, OpExecBatch, events[0].Operation)
+	assert.Equal(t, []any{"a3"}, events[1].Args)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_TemplateSetAndClone(t *testing.T) {
+	calls := make([]string, 0)
+	hook := &testHook{name: "set", calls: &calls}
+	set, err := NewTemplateSet[struct {
+		Select NamedTemplate `sql:"SELECT * FROM table WHERE col_a = :a"`
+	}](hook)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectExec(`SELECT * FROM table WHERE col_a = $1`).WithArgs("aa").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`SELECT * FROM table WHERE col_a = ? AND col_b = ?`).WithArgs("aa", "bb").WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err = set.Select.Clone(PostgresOption).Exec(db, map[string]any{"a": "aa"})
+	require.NoError(t, err)
+	_, err = set.Select.MustAppend(` AND col_b = :b`).Exec(db, map[string]any{"a": "aa", "b": "bb"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"before:set", "after:set", "before:set", "after:set"}, calls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_TemplateNameContext(t *testing.T) {
+	names := make([]string, 0)
+	hook := HookFuncs{
+		BeforeFunc: func(ctx context.Context, event *HookEvent) (context.Context, error) {
+			name, ok := TemplateNameFromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, event.Template.Name(), name)
+			names = append(names, name)
+			return ctx, nil
+		},
+	}
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, TemplateName("Select"), hook)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectExec(`SELECT * FROM table WHERE col_a = ?`).WithArgs("aa").WillReturnResult(sqlmock.NewResult(1, 1))
+	_, err = nt.Exec(db, map[string]any{"a": "aa"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Select"}, names)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, ok := TemplateNameFromContext(context.Background())
+	assert.False(t, ok)
+}
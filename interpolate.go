@@ -0,0 +1,214 @@
+package sqlnt
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redactedLiteral is the literal used by NamedTemplate.Interpolate for redacted args (see NamedTemplate.RedactedArgs)
+const redactedLiteral = "'[REDACTED]'"
+
+// Interpolate returns the sql statement with the supplied named args inlined as sql literals
+//
+// The returned statement is intended for logging & debugging only - it must never be executed (use StatementAndArgs
+// or Exec, Query etc. to execute the statement)
+//
+// Values are formatted according to the option (see LiteralFormatter) and named args denoted as redacted
+// (see NamedTemplate.RedactedArgs) are inlined as '[REDACTED]'
+//
+// Returns the same errors as StatementAndArgs (in which case the returned statement is the same as Statement)
+func (n *namedTemplate) Interpolate(args ...any) (string, error) {
+	statement, err := n.interpolate(args...)
+	return statement, n.namedError(err)
+}
+
+func (n *namedTemplate) interpolate(args ...any) (string, error) {
+	if n.runtime != nil {
+		v, err := n.variant(args...)
+		if err != nil {
+			return n.statement, err
+		}
+		return v.interpolate(args...)
+	}
+	mapped, err := mappedArgs(args...)
+	if err != nil {
+		return n.statement, err
+	} else if err = n.checkUnknownArgs(mapped); err != nil {
+		return n.statement, err
+	}
+	r := n.newRenderer()
+	r.interpolate = true
+	if err = r.renderSegments(n.segments, r.newScope(mapped)); err != nil {
+		return n.statement, err
+	}
+	return r.builder.String(), nil
+}
+
+// MustInterpolate is the same as Interpolate, except no error is returned (and panics on error)
+func (n *namedTemplate) MustInterpolate(args ...any) string {
+	statement, err := n.Interpolate(args...)
+	if err != nil {
+		panic(err)
+	}
+	return statement
+}
+
+// RedactedArgs returns a copy of the template where the values of the specified names of args are redacted by Interpolate
+// (e.g. passwords, tokens or personal data)
+func (n *namedTemplate) RedactedArgs(names ...string) NamedTemplate {
+	r := n.copy()
+	for _, name := range names {
+		if arg, ok := r.args[name]; ok {
+			arg.redacted = true
+		}
+	}
+	return r
+}
+
+// literal returns the sql literal(s) for the value items of a named arg
+func (n *namedTemplate) literal(name string, items []any) (string, error) {
+	if n.args[name].redacted {
+		return redactedLiteral, nil
+	}
+	literals := make([]string, len(items))
+	for i, item := range items {
+		v, err := literalValue(item)
+		if err != nil {
+			return "", &argsError{msg: fmt.Sprintf("named arg '%s' value failed: %s", name, err), err: err}
+		} else if v == nil {
+			literals[i] = "NULL"
+		} else {
+			literals[i] = n.literals.FormatLiteral(v)
+		}
+	}
+	return strings.Join(literals, ", "), nil
+}
+
+// literalValue resolves driver.Valuer values and de-references pointers
+func literalValue(v any) (any, error) {
+	for v != nil {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, nil
+		} else if valuer, ok := v.(driver.Valuer); ok {
+			dv, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			} else if _, ok = dv.(driver.Valuer); ok {
+				// avoid infinite loop of valuers returning valuers...
+				return dv, nil
+			}
+			v = dv
+		} else if rv.Kind() == reflect.Pointer {
+			v = rv.Elem().Interface()
+		} else {
+			break
+		}
+	}
+	return v, nil
+}
+
+// literalDialect is the formatting of sql literals for a database dialect
+type literalDialect struct {
+	escapeBackslashes bool
+	trueLiteral       string
+	falseLiteral      string
+	bytesPrefix       string
+	bytesSuffix       string
+	timePrefix        string
+	timeLayout        string
+}
+
+var (
+	ansiLiterals = &literalDialect{
+		trueLiteral:  "TRUE",
+		falseLiteral: "FALSE",
+		bytesPrefix:  "X'",
+		bytesSuffix:  "'",
+		timeLayout:   "2006-01-02 15:04:05.999999999-07:00",
+	}
+	mySqlLiterals = &literalDialect{
+		escapeBackslashes: true,
+		trueLiteral:       "TRUE",
+		falseLiteral:      "FALSE",
+		bytesPrefix:       "X'",
+		bytesSuffix:       "'",
+		timeLayout:        "2006-01-02 15:04:05.999999",
+	}
+	postgresLiterals = &literalDialect{
+		trueLiteral:  "TRUE",
+		falseLiteral: "FALSE",
+		bytesPrefix:  `'\x`,
+		bytesSuffix:  "'",
+		timeLayout:   "2006-01-02 15:04:05.999999-07:00",
+	}
+	sqlServerLiterals = &literalDialect{
+		trueLiteral:  "1",
+		falseLiteral: "0",
+		bytesPrefix:  "0x",
+		timeLayout:   "2006-01-02 15:04:05.9999999-07:00",
+	}
+	oracleLiterals = &literalDialect{
+		trueLiteral:  "1",
+		falseLiteral: "0",
+		bytesPrefix:  "HEXTORAW('",
+		bytesSuffix:  "')",
+		timePrefix:   "TIMESTAMP ",
+		timeLayout:   "2006-01-02 15:04:05.999999999 -07:00",
+	}
+	sqliteLiterals = &literalDialect{
+		trueLiteral:  "1",
+		falseLiteral: "0",
+		bytesPrefix:  "X'",
+		bytesSuffix:  "'",
+		timeLayout:   "2006-01-02 15:04:05.999999999-07:00",
+	}
+)
+
+func (d *literalDialect) FormatLiteral(v any) string {
+	switch vt := v.(type) {
+	case string:
+		return d.quote(vt)
+	case time.Time:
+		return d.timePrefix + d.quote(vt.Format(d.timeLayout))
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return d.quote(rv.String())
+	case reflect.Bool:
+		if rv.Bool() {
+			return d.trueLiteral
+		}
+		return d.falseLiteral
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		s := strconv.FormatFloat(f, 'g', -1, rv.Type().Bits())
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return d.quote(s)
+		}
+		return s
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return d.bytesPrefix + hex.EncodeToString(rv.Bytes()) + d.bytesSuffix
+		}
+	}
+	return d.quote(fmt.Sprint(v))
+}
+
+func (d *literalDialect) quote(s string) string {
+	if d.escapeBackslashes {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
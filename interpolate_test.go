@@ -0,0 +1,316 @@
+package sqlnt
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNamedTemplate_Interpolate(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a AND col_b = :b AND col_c = :a`, PostgresOption)
+	s, err := nt.Interpolate(map[string]any{"a": "it's", "b": 42})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = 'it''s' AND col_b = 42 AND col_c = 'it''s'`, s)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1 AND col_b = $2 AND col_c = $1`, nt.Statement())
+
+	s, err = nt.Interpolate(map[string]any{"a": nil, "b": nil})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = NULL AND col_b = NULL AND col_c = NULL`, s)
+
+	s, err = nt.Interpolate(map[string]any{"a": "x"})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidArgs))
+	assert.Equal(t, nt.Statement(), s)
+	assert.Panics(t, func() {
+		_ = nt.MustInterpolate(map[string]any{})
+	})
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = 'a' AND col_b = 'b' AND col_c = 'a'`, nt.MustInterpolate(map[string]any{"a": "a", "b": "b"}))
+}
+
+func TestNamedTemplate_Interpolate_Redacted(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO users (name, password, keys) VALUES (:name, :password, ARRAY[:keys...]), (:password)`, PostgresOption).
+		RedactedArgs("password", "keys", "unknown")
+	assert.True(t, nt.GetArgsInfo()["password"].Redacted)
+	assert.True(t, nt.GetArgsInfo()["keys"].Redacted)
+	assert.False(t, nt.GetArgsInfo()["name"].Redacted)
+	s, err := nt.Interpolate(map[string]any{"name": "bilbo", "password": "secret", "keys": []string{"k1", "k2"}})
+	require.NoError(t, err)
+	assert.Equal(t, `INSERT INTO users (name, password, keys) VALUES ('bilbo', '[REDACTED]', ARRAY['[REDACTED]']), ('[REDACTED]')`, s)
+	// statement and args are not affected by redaction...
+	_, args, err := nt.StatementAndArgs(map[string]any{"name": "bilbo", "password": "secret", "keys": []string{"k1", "k2"}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"bilbo", "secret", "k1", "k2"}, args)
+
+	nt2 := nt.Clone(MySqlOption)
+	assert.True(t, nt2.GetArgsInfo()["password"].Redacted)
+	s, err = nt2.Interpolate(map[string]any{"name": "bilbo", "password": "secret", "keys": []string{"k1"}})
+	require.NoError(t, err)
+	assert.Equal(t, `INSERT INTO users (name, password, keys) VALUES ('bilbo', '[REDACTED]', ARRAY['[REDACTED]']), ('[REDACTED]')`, s)
+}
+
+func TestNamedTemplate_Interpolate_ExpandingAndConditional(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE id IN (:ids...)[[ AND status = :status]]`, MySqlOption)
+	s, err := nt.Interpolate(map[string]any{"ids": []int{1, 2, 3}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE id IN (1, 2, 3)`, s)
+	s, err = nt.Interpolate(map[string]any{"ids": []int{1}, "status": true})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE id IN (1) AND status = TRUE`, s)
+	s, err = nt.Interpolate(map[string]any{"ids": []int{}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE id IN (NULL)`, s)
+}
+
+func TestNamedTemplate_Interpolate_RuntimeTokens(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a ORDER BY {{sort}}`, AllowedValuesToken("sort", "name"))
+	s, err := nt.Interpolate(map[string]any{"a": 1}, TokenValues{"sort": "name"})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = 1 ORDER BY name`, s)
+	_, err = nt.Interpolate(map[string]any{"a": 1}, TokenValues{"sort": "other"})
+	assert.Error(t, err)
+}
+
+func TestNamedTemplate_Interpolate_Errors(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, UnknownArgsError)
+	_, err := nt.Interpolate(map[string]any{"a": 1, "b": 2})
+	assert.Error(t, err)
+	assert.Equal(t, "unknown arg: b", err.Error())
+	_, err = nt.Interpolate(map[int]any{})
+	assert.Error(t, err)
+	_, err = nt.Interpolate(map[string]any{"a": &testLiteralValuer{err: errors.New("fooey")}})
+	assert.Error(t, err)
+	assert.Equal(t, "named arg 'a' value failed: fooey", err.Error())
+	assert.True(t, errors.Is(err, ErrInvalidArgs))
+}
+
+type testLiteralValuer struct {
+	value driver.Value
+	err   error
+}
+
+func (v *testLiteralValuer) Value() (driver.Value, error) {
+	return v.value, v.err
+}
+
+type testLiteralOption struct {
+	Option
+}
+
+func (o *testLiteralOption) FormatLiteral(v any) string {
+	return "<" + ansiLiterals.FormatLiteral(v) + ">"
+}
+
+func TestNamedTemplate_Interpolate_LiteralFormatter(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a AND col_b = :b`, &testLiteralOption{Option: MySqlOption})
+	s, err := nt.Interpolate(map[string]any{"a": "a", "b": nil})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = <'a'> AND col_b = NULL`, s)
+
+	nt = MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, &testOption{})
+	s, err = nt.Interpolate(map[string]any{"a": true})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = TRUE`, s)
+}
+
+type testStringType string
+
+func TestLiteralDialects(t *testing.T) {
+	str := "it's"
+	var nilStr *string
+	tm := time.Date(2023, 1, 2, 13, 14, 15, 123456000, time.FixedZone("", 3600))
+	bytes := []byte{0xde, 0xad, 0xbe, 0xef}
+	testCases := []struct {
+		value     any
+		ansi      string
+		mySql     string
+		postgres  string
+		sqlServer string
+		oracle    string
+		sqlite    string
+	}{
+		{
+			value:     nil,
+			ansi:      "NULL",
+			mySql:     "NULL",
+			postgres:  "NULL",
+			sqlServer: "NULL",
+			oracle:    "NULL",
+			sqlite:    "NULL",
+		},
+		{
+			value:     `it's a \ back`,
+			ansi:      `'it''s a \ back'`,
+			mySql:     `'it''s a \\ back'`,
+			postgres:  `'it''s a \ back'`,
+			sqlServer: `'it''s a \ back'`,
+			oracle:    `'it''s a \ back'`,
+			sqlite:    `'it''s a \ back'`,
+		},
+		{
+			value:     &str,
+			ansi:      `'it''s'`,
+			mySql:     `'it''s'`,
+			postgres:  `'it''s'`,
+			sqlServer: `'it''s'`,
+			oracle:    `'it''s'`,
+			sqlite:    `'it''s'`,
+		},
+		{
+			value:     nilStr,
+			ansi:      "NULL",
+			mySql:     "NULL",
+			postgres:  "NULL",
+			sqlServer: "NULL",
+			oracle:    "NULL",
+			sqlite:    "NULL",
+		},
+		{
+			value:     testStringType("x"),
+			ansi:      `'x'`,
+			mySql:     `'x'`,
+			postgres:  `'x'`,
+			sqlServer: `'x'`,
+			oracle:    `'x'`,
+			sqlite:    `'x'`,
+		},
+		{
+			value:     true,
+			ansi:      "TRUE",
+			mySql:     "TRUE",
+			postgres:  "TRUE",
+			sqlServer: "1",
+			oracle:    "1",
+			sqlite:    "1",
+		},
+		{
+			value:     false,
+			ansi:      "FALSE",
+			mySql:     "FALSE",
+			postgres:  "FALSE",
+			sqlServer: "0",
+			oracle:    "0",
+			sqlite:    "0",
+		},
+		{
+			value:     int8(-12),
+			ansi:      "-12",
+			mySql:     "-12",
+			postgres:  "-12",
+			sqlServer: "-12",
+			oracle:    "-12",
+			sqlite:    "-12",
+		},
+		{
+			value:     uint64(math.MaxUint64),
+			ansi:      "18446744073709551615",
+			mySql:     "18446744073709551615",
+			postgres:  "18446744073709551615",
+			sqlServer: "18446744073709551615",
+			oracle:    "18446744073709551615",
+			sqlite:    "18446744073709551615",
+		},
+		{
+			value:     float32(1.1),
+			ansi:      "1.1",
+			mySql:     "1.1",
+			postgres:  "1.1",
+			sqlServer: "1.1",
+			oracle:    "1.1",
+			sqlite:    "1.1",
+		},
+		{
+			value:     math.Inf(-1),
+			ansi:      "'-Inf'",
+			mySql:     "'-Inf'",
+			postgres:  "'-Inf'",
+			sqlServer: "'-Inf'",
+			oracle:    "'-Inf'",
+			sqlite:    "'-Inf'",
+		},
+		{
+			value:     bytes,
+			ansi:      "X'deadbeef'",
+			mySql:     "X'deadbeef'",
+			postgres:  `'\xdeadbeef'`,
+			sqlServer: "0xdeadbeef",
+			oracle:    "HEXTORAW('deadbeef')",
+			sqlite:    "X'deadbeef'",
+		},
+		{
+			value:     json.RawMessage(`{}`),
+			ansi:      "X'7b7d'",
+			mySql:     "X'7b7d'",
+			postgres:  `'\x7b7d'`,
+			sqlServer: "0x7b7d",
+			oracle:    "HEXTORAW('7b7d')",
+			sqlite:    "X'7b7d'",
+		},
+		{
+			value:     tm,
+			ansi:      "'2023-01-02 13:14:15.123456+01:00'",
+			mySql:     "'2023-01-02 13:14:15.123456'",
+			postgres:  "'2023-01-02 13:14:15.123456+01:00'",
+			sqlServer: "'2023-01-02 13:14:15.123456+01:00'",
+			oracle:    "TIMESTAMP '2023-01-02 13:14:15.123456 +01:00'",
+			sqlite:    "'2023-01-02 13:14:15.123456+01:00'",
+		},
+		{
+			value:     sql.NullString{String: "a", Valid: true},
+			ansi:      "'a'",
+			mySql:     "'a'",
+			postgres:  "'a'",
+			sqlServer: "'a'",
+			oracle:    "'a'",
+			sqlite:    "'a'",
+		},
+		{
+			value:     sql.NullInt64{},
+			ansi:      "NULL",
+			mySql:     "NULL",
+			postgres:  "NULL",
+			sqlServer: "NULL",
+			oracle:    "NULL",
+			sqlite:    "NULL",
+		},
+		{
+			value:     &testLiteralValuer{value: int64(1)},
+			ansi:      "1",
+			mySql:     "1",
+			postgres:  "1",
+			sqlServer: "1",
+			oracle:    "1",
+			sqlite:    "1",
+		},
+		{
+			value:     map[string]any{"a": 1},
+			ansi:      "'map[a:1]'",
+			mySql:     "'map[a:1]'",
+			postgres:  "'map[a:1]'",
+			sqlServer: "'map[a:1]'",
+			oracle:    "'map[a:1]'",
+			sqlite:    "'map[a:1]'",
+		},
+	}
+	for i, tc := range testCases {
+		expects := map[Option]string{
+			DefaultsOption:  tc.ansi,
+			MySqlOption:     tc.mySql,
+			PostgresOption:  tc.postgres,
+			SqlServerOption: tc.sqlServer,
+			OracleOption:    tc.oracle,
+			SqliteOption:    tc.sqlite,
+		}
+		for option, expect := range expects {
+			nt := MustCreateNamedTemplate(`:a`, option)
+			s, err := nt.Interpolate(map[string]any{"a": tc.value})
+			require.NoError(t, err, "[%d]", i)
+			assert.Equal(t, expect, s, "[%d] %s", i, option.ArgTag())
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package sqlnt
 
+import (
+	"fmt"
+	"reflect"
+)
+
 // ArgInfo is the info about a named arg returned from NamedTemplate.GetArgsInfo
 type ArgInfo struct {
 	// Tag is the final arg tag used for the named arg
@@ -13,6 +18,18 @@ type ArgInfo struct {
 	// NullableString denotes whether the named arg is a nullable string
 	// (i.e. if the supplied value is an empty, then nil is used)
 	NullableString bool
+	// Expand denotes whether the named arg is expanded when the supplied value is a slice
+	// (denoted by '...' after the name in the template - e.g. `WHERE id IN (:ids...)`)
+	Expand bool
+	// Converters is the ArgConverterFunc converters applied (in order) to the supplied value
+	// (see NamedTemplate.ArgConverter)
+	Converters []ArgConverterFunc
+	// Type is the annotated type of the named arg (e.g. "int" or "[]int") - or empty if not annotated
+	// (denoted by ':type' after the name in the template - e.g. `WHERE age > :age:int`)
+	Type string
+	// Redacted denotes whether the named arg value is redacted by NamedTemplate.Interpolate
+	// (see NamedTemplate.RedactedArgs)
+	Redacted bool
 }
 
 type namedArg struct {
@@ -20,23 +37,35 @@ type namedArg struct {
 	positions      []int
 	omissible      bool
 	defValue       DefaultValueFunc
+	defSource      *defaultSource
 	nullableString bool
+	converters     []ArgConverterFunc
+	expand         bool
+	typ            string
+	redacted       bool
 }
 
 func (a *namedArg) toInfo() ArgInfo {
 	return ArgInfo{
 		Tag:            a.tag,
-		Positions:      a.positions,
+		Positions:      append([]int{}, a.positions...),
 		Omissible:      a.omissible,
 		DefaultValue:   a.defValue,
 		NullableString: a.nullableString,
+		Converters:     append([]ArgConverterFunc{}, a.converters...),
+		Expand:         a.expand,
+		Type:           a.typ,
+		Redacted:       a.redacted,
 	}
 }
 
 func (a *namedArg) copyOptionsTo(r *namedArg) {
 	r.omissible = a.omissible
 	r.defValue = a.defValue
+	r.defSource = a.defSource
 	r.nullableString = a.nullableString
+	r.converters = a.converters
+	r.redacted = a.redacted
 }
 
 func (a *namedArg) clone() *namedArg {
@@ -45,8 +74,72 @@ func (a *namedArg) clone() *namedArg {
 		positions:      a.positions,
 		omissible:      a.omissible,
 		defValue:       a.defValue,
+		defSource:      a.defSource,
 		nullableString: a.nullableString,
+		converters:     a.converters,
+		expand:         a.expand,
+		typ:            a.typ,
+		redacted:       a.redacted,
+	}
+}
+
+// mergeOptions merges the options of another arg (of the same name) into the arg - returning the names of any conflicting options
+//
+// Nullable string and redacted are combined (i.e. set if set on either arg) - default values and converters
+// conflict when set differently on both args (see sameDefault and sameConverters) - and omissible conflicts when
+// only one of the args is omissible (unless there is a default value, which makes the arg omissible)
+func (a *namedArg) mergeOptions(o *namedArg) []string {
+	conflicts := make([]string, 0)
+	a.nullableString = a.nullableString || o.nullableString
+	a.redacted = a.redacted || o.redacted
+	if o.defValue != nil {
+		if a.defValue == nil {
+			a.defValue = o.defValue
+			a.defSource = o.defSource
+		} else if !sameDefault(a.defSource, o.defSource) {
+			conflicts = append(conflicts, "default value")
+		}
 	}
+	if len(o.converters) > 0 {
+		if len(a.converters) == 0 {
+			a.converters = o.converters
+		} else if !sameConverters(a.converters, o.converters) {
+			conflicts = append(conflicts, "converters")
+		}
+	}
+	if a.omissible != o.omissible {
+		if a.defValue == nil {
+			conflicts = append(conflicts, "omissible")
+		}
+		a.omissible = true
+	}
+	return conflicts
+}
+
+// defaultSource is the value (or func) supplied to NamedTemplate.DefaultValue - where the pointer identifies the call
+// (and is shared by copies of the arg)
+type defaultSource struct {
+	value any
+}
+
+// sameDefault determines whether default values are the same - i.e. set by the same call to NamedTemplate.DefaultValue
+// or equal values
+//
+// NB. func default values are only the same when set by the same call (as funcs cannot be compared - and funcs with the
+// same code may be closures of different values)
+func sameDefault(d1 *defaultSource, d2 *defaultSource) bool {
+	if d1 == d2 {
+		return true
+	} else if reflect.ValueOf(d1.value).Kind() == reflect.Func || reflect.ValueOf(d2.value).Kind() == reflect.Func {
+		return false
+	}
+	return reflect.DeepEqual(d1.value, d2.value)
+}
+
+// sameConverters determines whether (non-empty) converters are the same - i.e. set by the same calls to NamedTemplate.ArgConverter
+// (as funcs cannot be compared)
+func sameConverters(c1 []ArgConverterFunc, c2 []ArgConverterFunc) bool {
+	return len(c1) == len(c2) && &c1[0] == &c2[0]
 }
 
 func (a *namedArg) setOmissible(omissible bool) {
@@ -56,8 +149,32 @@ func (a *namedArg) setOmissible(omissible bool) {
 	}
 }
 
-func (a *namedArg) defaultedValue(name string) any {
-	return a.value(a.defValue(name))
+// resolve returns the value for the named arg from the supplied mapped args (or the default value if not supplied)
+//
+// returns an error if the named arg is not supplied and is not omissible (or the value cannot be converted to the annotated type)
+func (a *namedArg) resolve(name string, mapped map[string]any) (any, error) {
+	if v, ok, missing, err := lookupArg(name, mapped); err != nil {
+		return nil, err
+	} else if ok {
+		return a.convert(name, v)
+	} else if !a.omissible {
+		return nil, &MissingArgsError{Names: []string{name}, Reasons: map[string]string{name: missing}}
+	} else if a.defValue != nil {
+		return a.convert(name, a.defValue(name))
+	}
+	return nil, nil
+}
+
+// convert applies the nullable string, converters and annotated type conversion to the supplied value
+func (a *namedArg) convert(name string, v any) (any, error) {
+	v = a.value(v)
+	for _, converter := range a.converters {
+		var err error
+		if v, err = converter(v); err != nil {
+			return nil, &argsError{msg: fmt.Sprintf("named arg '%s' converter failed: %s", name, err), err: err}
+		}
+	}
+	return convertArg(name, a.typ, v)
 }
 
 func (a *namedArg) value(v any) any {
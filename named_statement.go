@@ -0,0 +1,235 @@
+package sqlnt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// Preparer is the interface used by NamedTemplate.Prepare to prepare statements
+//
+// Satisfied by *sql.DB, *sql.Tx and *sql.Conn (and any wrappers of those)
+type Preparer interface {
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// NamedStatement is a prepared statement for a NamedTemplate - that accepts the same named args as NamedTemplate.Args
+//
+// Use NamedTemplate.Prepare or StatementCache.Prepare to create a new one
+type NamedStatement interface {
+	// Template returns the NamedTemplate from which the statement was prepared
+	Template() NamedTemplate
+	// Stmt returns the underlying prepared sql.Stmt
+	Stmt() *sql.Stmt
+	// Exec performs sql.Stmt.Exec with the supplied named args
+	Exec(args ...any) (sql.Result, error)
+	// ExecContext performs sql.Stmt.ExecContext with the supplied named args
+	ExecContext(ctx context.Context, args ...any) (sql.Result, error)
+	// Query performs sql.Stmt.Query with the supplied named args
+	Query(args ...any) (*sql.Rows, error)
+	// QueryContext performs sql.Stmt.QueryContext with the supplied named args
+	QueryContext(ctx context.Context, args ...any) (*sql.Rows, error)
+	// QueryRow performs sql.Stmt.QueryRow with the supplied named args
+	//
+	// Returns an error if the supplied named args cannot be converted - errors from the query itself
+	// are deferred until sql.Row.Scan is called
+	QueryRow(args ...any) (*sql.Row, error)
+	// QueryRowContext performs sql.Stmt.QueryRowContext with the supplied named args
+	//
+	// Returns an error if the supplied named args cannot be converted - errors from the query itself
+	// are deferred until sql.Row.Scan is called
+	QueryRowContext(ctx context.Context, args ...any) (*sql.Row, error)
+	// Close closes the underlying prepared sql.Stmt
+	//
+	// NB. For statements obtained from a StatementCache, Close does nothing (use StatementCache.Close instead)
+	Close() error
+}
+
+type namedStatement struct {
+	template *namedTemplate
+	stmt     *sql.Stmt
+}
+
+var (
+	errPrepareExpanding   = errors.New("cannot prepare template with expanding named args")
+	errPrepareConditional = errors.New("cannot prepare template with conditional fragments")
+	errPrepareRuntime     = errors.New("cannot prepare template with runtime tokens")
+)
+
+// Prepare prepares the named template statement on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn)
+//
+// Returns an error if the template has expanding named args, conditional fragments or runtime tokens (as the statement varies according to the supplied args)
+func (n *namedTemplate) Prepare(ctx context.Context, db Preparer) (NamedStatement, error) {
+	if n.expanding {
+		return nil, errPrepareExpanding
+	} else if n.conditional {
+		return nil, errPrepareConditional
+	} else if n.runtime != nil {
+		return nil, errPrepareRuntime
+	}
+	stmt, err := db.PrepareContext(ctx, n.statement)
+	if err != nil {
+		return nil, err
+	}
+	return &namedStatement{
+		template: n,
+		stmt:     stmt,
+	}, nil
+}
+
+// Template returns the NamedTemplate from which the statement was prepared
+func (s *namedStatement) Template() NamedTemplate {
+	return s.template
+}
+
+// Stmt returns the underlying prepared sql.Stmt
+func (s *namedStatement) Stmt() *sql.Stmt {
+	return s.stmt
+}
+
+// Exec performs sql.Stmt.Exec with the supplied named args
+func (s *namedStatement) Exec(args ...any) (sql.Result, error) {
+	return s.ExecContext(context.Background(), args...)
+}
+
+// ExecContext performs sql.Stmt.ExecContext with the supplied named args
+func (s *namedStatement) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	qargs, err := s.template.Args(args...)
+	if err != nil {
+		return nil, err
+	}
+	event := s.newHookEvent(OpExec, qargs)
+	err = s.template.execute(ctx, event, func(ctx context.Context) (err error) {
+		event.Result, err = s.stmt.ExecContext(ctx, event.Args...)
+		return
+	})
+	return event.Result, err
+}
+
+// Query performs sql.Stmt.Query with the supplied named args
+func (s *namedStatement) Query(args ...any) (*sql.Rows, error) {
+	return s.QueryContext(context.Background(), args...)
+}
+
+// QueryContext performs sql.Stmt.QueryContext with the supplied named args
+func (s *namedStatement) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
+	qargs, err := s.template.Args(args...)
+	if err != nil {
+		return nil, err
+	}
+	event := s.newHookEvent(OpQuery, qargs)
+	err = s.template.execute(ctx, event, func(ctx context.Context) (err error) {
+		event.Rows, err = s.stmt.QueryContext(ctx, event.Args...)
+		return
+	})
+	return event.Rows, err
+}
+
+// QueryRow performs sql.Stmt.QueryRow with the supplied named args
+func (s *namedStatement) QueryRow(args ...any) (*sql.Row, error) {
+	return s.QueryRowContext(context.Background(), args...)
+}
+
+// QueryRowContext performs sql.Stmt.QueryRowContext with the supplied named args
+func (s *namedStatement) QueryRowContext(ctx context.Context, args ...any) (*sql.Row, error) {
+	qargs, err := s.template.Args(args...)
+	if err != nil {
+		return nil, err
+	}
+	event := s.newHookEvent(OpQueryRow, qargs)
+	err = s.template.execute(ctx, event, func(ctx context.Context) error {
+		event.Row = s.stmt.QueryRowContext(ctx, event.Args...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event.Row, nil
+}
+
+// Close closes the underlying prepared sql.Stmt
+//
+// NB. For statements obtained from a StatementCache, Close does nothing (use StatementCache.Close instead)
+func (s *namedStatement) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *namedStatement) newHookEvent(op Operation, args []any) *HookEvent {
+	event := s.template.newHookEvent(op, s.template.statement, args)
+	event.Prepared = true
+	return event
+}
+
+// StatementCache is a cache of prepared statements - keyed by template and db
+//
+// Use NewStatementCache to create a new one
+type StatementCache struct {
+	mutex      sync.Mutex
+	statements map[statementCacheKey]*cachedStatement
+}
+
+type statementCacheKey struct {
+	template NamedTemplate
+	db       *sql.DB
+}
+
+// cachedStatement is a NamedStatement held in a StatementCache - where Close does nothing
+type cachedStatement struct {
+	NamedStatement
+}
+
+// Close does nothing for cached statements (use StatementCache.Close instead)
+func (s *cachedStatement) Close() error {
+	return nil
+}
+
+// NewStatementCache creates a new StatementCache
+func NewStatementCache() *StatementCache {
+	return &StatementCache{
+		statements: map[statementCacheKey]*cachedStatement{},
+	}
+}
+
+// Prepare returns the prepared statement for the supplied template on the supplied db - preparing it only if
+// it has not already been prepared (and cached)
+//
+// Returns an error if the template has expanding named args, conditional fragments or runtime tokens (as the statement varies according to the supplied args)
+func (c *StatementCache) Prepare(ctx context.Context, template NamedTemplate, db *sql.DB) (NamedStatement, error) {
+	key := statementCacheKey{template: template, db: db}
+	c.mutex.Lock()
+	s, ok := c.statements[key]
+	c.mutex.Unlock()
+	if ok {
+		return s, nil
+	}
+	ns, err := template.Prepare(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if s, ok = c.statements[key]; ok {
+		// another goroutine prepared (and cached) the same statement in the meantime...
+		_ = ns.Close()
+		return s, nil
+	}
+	s = &cachedStatement{ns}
+	c.statements[key] = s
+	return s, nil
+}
+
+// Close closes all cached prepared statements (and empties the cache)
+//
+// Returns the first error encountered closing statements (if any)
+func (c *StatementCache) Close() (err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, s := range c.statements {
+		if cErr := s.NamedStatement.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+		delete(c.statements, key)
+	}
+	return
+}
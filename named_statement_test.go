@@ -0,0 +1,111 @@
+package sqlnt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNamedTemplate_Prepare(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a OR col_b = :b OR col_c = :a`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	prep := mock.ExpectPrepare(nt.Statement())
+	prep.ExpectExec().WithArgs("aa", "bb", "aa").WillReturnResult(sqlmock.NewResult(1, 1))
+	prep.ExpectQuery().WithArgs("cc", "dd", "cc").WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	prep.ExpectQuery().WithArgs("ee", "ff", "ee").WillReturnRows(sqlmock.NewRows([]string{"col_a"}).AddRow("ee"))
+	prep.WillBeClosed()
+
+	stmt, err := nt.Prepare(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, nt, stmt.Template())
+	assert.NotNil(t, stmt.Stmt())
+
+	_, err = stmt.Exec(map[string]any{"a": "aa", "b": "bb"})
+	assert.NoError(t, err)
+	rows, err := stmt.Query(map[string]any{"a": "cc", "b": "dd"})
+	assert.NoError(t, err)
+	require.NoError(t, rows.Close())
+	row, err := stmt.QueryRow(map[string]any{"a": "ee", "b": "ff"})
+	require.NoError(t, err)
+	var a string
+	assert.NoError(t, row.Scan(&a))
+	assert.Equal(t, "ee", a)
+
+	_, err = stmt.Exec(map[string]any{"a": "aa"})
+	assert.Error(t, err)
+	_, err = stmt.Query(map[string]any{"a": "aa"})
+	assert.Error(t, err)
+	_, err = stmt.QueryRow(map[string]any{"a": "aa"})
+	assert.Error(t, err)
+
+	assert.NoError(t, stmt.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamedTemplate_Prepare_Errors(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+
+	_, err = MustCreateNamedTemplate(`SELECT * FROM table WHERE id IN (:ids...)`).Prepare(context.Background(), db)
+	assert.Error(t, err)
+	assert.Equal(t, "cannot prepare template with expanding named args", err.Error())
+
+	_, err = MustCreateNamedTemplate(`SELECT * FROM table WHERE 1=1[[ AND col_a = :a]]`).Prepare(context.Background(), db)
+	assert.Error(t, err)
+	assert.Equal(t, "cannot prepare template with conditional fragments", err.Error())
+
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`)
+	mock.ExpectPrepare(nt.Statement()).WillReturnError(errors.New("fooey"))
+	_, err = nt.Prepare(context.Background(), db)
+	assert.Error(t, err)
+	assert.Equal(t, "fooey", err.Error())
+}
+
+func TestStatementCache(t *testing.T) {
+	ts := MustCreateTemplateSet[struct {
+		Select NamedTemplate `sql:"SELECT * FROM table WHERE col_a = :a"`
+		Delete NamedTemplate `sql:"DELETE FROM table WHERE col_a = :a"`
+	}]()
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	prepSelect := mock.ExpectPrepare(ts.Select.Statement())
+	prepDelete := mock.ExpectPrepare(ts.Delete.Statement())
+	prepSelect.ExpectQuery().WithArgs("aa").WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	prepDelete.ExpectExec().WithArgs("bb").WillReturnResult(sqlmock.NewResult(0, 1))
+	prepSelect.ExpectQuery().WithArgs("cc").WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	prepSelect.WillBeClosed()
+	prepDelete.WillBeClosed()
+
+	cache := NewStatementCache()
+	ctx := context.Background()
+	selStmt, err := cache.Prepare(ctx, ts.Select, db)
+	require.NoError(t, err)
+	delStmt, err := cache.Prepare(ctx, ts.Delete, db)
+	require.NoError(t, err)
+	rows, err := selStmt.QueryContext(ctx, sql.Named("a", "aa"))
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	_, err = delStmt.ExecContext(ctx, sql.Named("a", "bb"))
+	require.NoError(t, err)
+	// closing cached statement does nothing...
+	assert.NoError(t, selStmt.Close())
+
+	selStmt2, err := cache.Prepare(ctx, ts.Select, db)
+	require.NoError(t, err)
+	assert.Same(t, selStmt, selStmt2)
+	rows, err = selStmt2.QueryContext(ctx, sql.Named("a", "cc"))
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	assert.NoError(t, cache.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, 0, len(cache.statements))
+
+	_, err = cache.Prepare(ctx, MustCreateNamedTemplate(`SELECT * FROM table WHERE id IN (:ids...)`), db)
+	assert.Error(t, err)
+}
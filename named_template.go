@@ -3,24 +3,73 @@ package sqlnt
 import (
 	"context"
 	"database/sql"
-	"fmt"
 )
 
-// NamedTemplate represents a named template
+// Execer is the interface used by NamedTemplate.Exec and NamedTemplate.ExecContext to execute statements
 //
-// Use NewNamedTemplate or MustCreateNamedTemplate to create a new one
+// Satisfied by *sql.DB, *sql.Tx and *sql.Conn (and any wrappers of those)
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Querier is the interface used by NamedTemplate.Query and NamedTemplate.QueryContext to query statements
+//
+// Satisfied by *sql.DB, *sql.Tx and *sql.Conn (and any wrappers of those)
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// RowQuerier is the interface used by NamedTemplate.QueryRow and NamedTemplate.QueryRowContext to query a single row
+//
+// Satisfied by *sql.DB, *sql.Tx and *sql.Conn (and any wrappers of those)
+type RowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// NamedTemplate represents a named template - use NewNamedTemplate or MustCreateNamedTemplate to create a new one
+//
+// A NamedTemplate is immutable once created - methods that configure args (e.g. OmissibleArgs, DefaultValue) return
+// a configured copy and leave the original unchanged - so templates are safe for concurrent use
 type NamedTemplate interface {
+	// Name returns the name of the template - or empty if the template is not named
+	//
+	// Templates created by NewTemplateSet are named by their field path (e.g. "Users.Select") - otherwise templates
+	// are named using the TemplateName option
+	Name() string
 	// Statement returns the sql statement to use (with named args transposed)
+	//
+	// NB. Where the template has expanding named args (e.g. `WHERE id IN (:ids...)`), the statement
+	// has a single placeholder for each expanding arg - use StatementAndArgs to obtain the expanded statement
+	//
+	// Where the template has conditional fragments (e.g. `[[ AND status = :status ]]`), the statement includes all fragments
+	//
+	// Where the template has runtime tokens (see RuntimeToken), the statement contains the unreplaced tokens
 	Statement() string
 	// StatementAndArgs returns the sql statement to use (with named args transposed) and
 	// the input named args converted to positional args
 	//
-	// Essentially the same as calling Statement and then Args
+	// Essentially the same as calling Statement and then Args - except where the template has expanding named args
+	// (e.g. `WHERE id IN (:ids...)`), in which case the returned statement has a placeholder for each supplied slice item
+	//
+	// Conditional fragments (e.g. `[[ AND status = :status ]]`) are only included in the returned statement when all
+	// the named args within the fragment are supplied
 	StatementAndArgs(args ...any) (string, []any, error)
 	// MustStatementAndArgs is the same as StatementAndArgs, except no error is returned (and panics on error)
 	MustStatementAndArgs(args ...any) (string, []any)
 	// OriginalStatement returns the original named template statement
 	OriginalStatement() string
+	// Interpolate returns the sql statement with the supplied named args inlined as sql literals
+	//
+	// The returned statement is intended for logging & debugging only - it must never be executed (use StatementAndArgs
+	// or Exec, Query etc. to execute the statement)
+	//
+	// Values are formatted according to the option (see LiteralFormatter) and named args denoted as redacted
+	// (see NamedTemplate.RedactedArgs) are inlined as '[REDACTED]'
+	//
+	// Returns the same errors as StatementAndArgs (in which case the returned statement is the same as Statement)
+	Interpolate(args ...any) (string, error)
+	// MustInterpolate is the same as Interpolate, except no error is returned (and panics on error)
+	MustInterpolate(args ...any) string
 	// Args converts the input named args to positional args (for use in db.Exec, db.Query etc.)
 	//
 	// Each arg in the supplied args can be:
@@ -31,18 +80,26 @@ type NamedTemplate interface {
 	//
 	// * or any map where all keys are set as string
 	//
-	// * or anything that can be marshalled and then unmarshalled to map[string]any (such as structs!)
+	// * a struct (or pointer to struct) - where each field is mapped to a named arg by its `db` tag, `json` tag or field name
+	//
+	// * or anything else that can be marshalled and then unmarshalled to map[string]any
 	//
 	// If any of the named args specified in the query are missing, returns an error
 	//
 	// NB. named args are not considered missing when they have denoted as omissible (see NamedTemplate.OmissibleArgs) or
 	// have been set with a default value (see NamedTemplate.DefaultValue)
+	//
+	// If the template was created with the UnknownArgsError option (or DefaultStrictArgs was set), returns an error if any
+	// of the supplied args are not used by the template
+	//
+	// Where the template has expanding named args (e.g. `WHERE id IN (:ids...)`) or conditional fragments
+	// (e.g. `[[ AND status = :status ]]`), the returned args are those for the statement returned by StatementAndArgs
 	Args(args ...any) ([]any, error)
 	// MustArgs is the same as Args, except no error is returned (and panics on error)
 	MustArgs(args ...any) []any
 	// ArgsCount returns the number of args that are passed into the statement
 	ArgsCount() int
-	// OmissibleArgs specifies the names of args that can be omitted
+	// OmissibleArgs returns a copy of the template where the specified names of args can be omitted
 	//
 	// Calling this without any names makes all args omissible
 	//
@@ -50,7 +107,7 @@ type NamedTemplate interface {
 	//    tmp := sqlnt.MustCreateNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b?)`)
 	// makes the named arg "b" omissible (denoted by the '?' after name)
 	OmissibleArgs(names ...string) NamedTemplate
-	// DefaultValue specifies a value to be used for a given arg name when the arg
+	// DefaultValue returns a copy of the template with a value to be used for a given arg name when the arg
 	// is not supplied in the map for Args or MustArgs
 	//
 	// Setting a default value for an arg name also makes that arg omissible
@@ -59,9 +116,20 @@ type NamedTemplate interface {
 	//   func(name string) any
 	// then that func is called to obtain the default value
 	DefaultValue(name string, v any) NamedTemplate
-	// NullableStringArgs specifies the names of args that are nullable string
+	// NullableStringArgs returns a copy of the template where the specified names of args are nullable string
 	// i.e. where the value is an empty string, null is used instead
 	NullableStringArgs(names ...string) NamedTemplate
+	// ArgConverter returns a copy of the template with converters added for a given arg name - where each converter
+	// is applied (in order) to the supplied (or default) value
+	//
+	// Converters already set for the arg name are retained (the supplied converters are applied after them)
+	//
+	// See NullableZeroConverter, TrimConverter, LowerCaseConverter, JsonConverter and EnumStringConverter
+	// for built-in converters
+	ArgConverter(name string, converters ...ArgConverterFunc) NamedTemplate
+	// RedactedArgs returns a copy of the template where the values of the specified names of args are redacted by Interpolate
+	// (e.g. passwords, tokens or personal data)
+	RedactedArgs(names ...string) NamedTemplate
 	// GetArgNames returns a map of the arg names (where the map value is a bool indicating whether
 	// the arg is omissible
 	//
@@ -72,46 +140,139 @@ type NamedTemplate interface {
 	// NB. Each arg info is immutable - changing it has no effect on the template
 	GetArgsInfo() map[string]ArgInfo
 	// Clone clones the named template to another with a different option
+	//
+	// If the statement cannot be parsed under the option (e.g. `'a\'` is an unterminated string where the option recognises
+	// backslash escapes), the returned template is an unchanged copy
 	Clone(option Option) NamedTemplate
 	// Append appends a statement portion to current statement and returns a new NamedTemplate
 	//
+	// The arg options (omissible, default values, converters etc.) of the template are preserved in the new template
+	//
 	// Returns an error if the supplied statement portion cannot be parsed for arg names
 	Append(portion string) (NamedTemplate, error)
 	// MustAppend is the same as Append, except no error is returned (and panics on error)
 	MustAppend(portion string) NamedTemplate
-	// Exec performs sql.DB.Exec on the supplied db with the supplied named args
-	Exec(db *sql.DB, args ...any) (sql.Result, error)
-	// ExecContext performs sql.DB.ExecContext on the supplied db with the supplied named args
-	ExecContext(ctx context.Context, db *sql.DB, args ...any) (sql.Result, error)
-	// Query performs sql.DB.Query on the supplied db with the supplied named args
-	Query(db *sql.DB, args ...any) (*sql.Rows, error)
-	// QueryContext performs sql.DB.QueryContext on the supplied db with the supplied named args
-	QueryContext(ctx context.Context, db *sql.DB, args ...any) (*sql.Rows, error)
+	// Prepend prepends a statement portion to current statement and returns a new NamedTemplate
+	//
+	// The arg options (omissible, default values, converters etc.) of the template are preserved in the new template
+	//
+	// Returns an error if the supplied statement portion cannot be parsed for arg names
+	Prepend(portion string) (NamedTemplate, error)
+	// MustPrepend is the same as Prepend, except no error is returned (and panics on error)
+	MustPrepend(portion string) NamedTemplate
+	// Wrap surrounds the current statement with a prefix and suffix statement portion and returns a new NamedTemplate
+	// (e.g. to wrap a query as a sub-select)
+	//
+	// The arg options (omissible, default values, converters etc.) of the template are preserved in the new template
+	//
+	// Returns an error if the supplied statement portions cannot be parsed for arg names
+	Wrap(prefix string, suffix string) (NamedTemplate, error)
+	// MustWrap is the same as Wrap, except no error is returned (and panics on error)
+	MustWrap(prefix string, suffix string) NamedTemplate
+	// Embed appends the statement of the sub template - with its named args renamed to `prefix.name` - and returns
+	// a new NamedTemplate
+	//
+	// The arg options of the sub template are preserved (for the renamed args) - and the embedded args can be supplied
+	// as nested maps or structs (see dotted arg names) - example:
+	//    tenantFilter := sqlnt.MustCreateNamedTemplate(` AND tenant_id = :id`)
+	//    tmp := sqlnt.MustCreateNamedTemplate(`SELECT * FROM users WHERE id = :id`).MustEmbed(tenantFilter, "tenant")
+	//    args, err := tmp.Args(map[string]any{"id": 1, "tenant": map[string]any{"id": 2}})
+	//
+	// Returns an error if the prefix is not a valid arg name, the templates have different marker syntax or the
+	// sub template args conflict with existing args (see Concat)
+	Embed(sub NamedTemplate, prefix string) (NamedTemplate, error)
+	// MustEmbed is the same as Embed, except no error is returned (and panics on error)
+	MustEmbed(sub NamedTemplate, prefix string) NamedTemplate
+	// WithHooks returns a copy of the template with the additional hooks (see Hook)
+	WithHooks(hooks ...Hook) NamedTemplate
+	// Exec performs an exec on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+	Exec(db Execer, args ...any) (sql.Result, error)
+	// ExecContext performs an exec on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+	ExecContext(ctx context.Context, db Execer, args ...any) (sql.Result, error)
+	// Query performs a query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+	Query(db Querier, args ...any) (*sql.Rows, error)
+	// QueryContext performs a query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+	QueryContext(ctx context.Context, db Querier, args ...any) (*sql.Rows, error)
+	// QueryRow performs a single row query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+	//
+	// Returns an error if the supplied named args cannot be converted - errors from the query itself
+	// are deferred until sql.Row.Scan is called (as per sql.DB.QueryRow)
+	QueryRow(db RowQuerier, args ...any) (*sql.Row, error)
+	// QueryRowContext performs a single row query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+	//
+	// Returns an error if the supplied named args cannot be converted - errors from the query itself
+	// are deferred until sql.Row.Scan is called (as per sql.DB.QueryRowContext)
+	QueryRowContext(ctx context.Context, db RowQuerier, args ...any) (*sql.Row, error)
+	// Prepare prepares the named template statement on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn)
+	//
+	// The returned NamedStatement accepts the same named args as Args
+	//
+	// Returns an error if the template has expanding named args, conditional fragments or runtime tokens (as the statement varies according to the supplied args)
+	Prepare(ctx context.Context, db Preparer) (NamedStatement, error)
+	// Batches returns the statements and args for the supplied rows (a slice of arg sets - each a map, struct etc. as per Args)
+	//
+	// The template must have a repeatable group - denoted by `...` after the closing parenthesis - example:
+	//    tmp := sqlnt.MustCreateNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`)
+	// where the group is repeated for each row (e.g. `VALUES (?, ?), (?, ?), (?, ?)`)
+	//
+	// The supplied args are common to all rows (for named args not supplied in a row)
+	//
+	// Rows are chunked into multiple batches where the number of args would exceed the max args for the
+	// option (see MaxArgsOption)
+	Batches(rows any, args ...any) ([]Batch, error)
+	// ExecBatch performs an exec of each batch (see Batches) on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn)
+	//
+	// NB. Where rows are chunked into multiple batches, use a *sql.Tx for the batches to be atomic
+	ExecBatch(db Execer, rows any, args ...any) (sql.Result, error)
+	// ExecBatchContext performs an exec of each batch (see Batches) on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn)
+	//
+	// NB. Where rows are chunked into multiple batches, use a *sql.Tx for the batches to be atomic
+	ExecBatchContext(ctx context.Context, db Execer, rows any, args ...any) (sql.Result, error)
 }
 
 type namedTemplate struct {
+	name              string
 	originalStatement string
 	statement         string
 	args              map[string]*namedArg
+	argNames          []string
+	nameSpans         []nameSpan
 	argsCount         int
+	option            Option
 	usePositionalTags bool
 	argTag            string
+	preserveCasts     bool
+	backslashEscapes  bool
+	hashComments      bool
+	formatter         ArgTagFormatter
+	literals          LiteralFormatter
+	maxArgs           int
 	tokenOptions      []TokenOption
+	runtime           *runtimeTokens
+	markers           MarkerSyntax
+	emptySlices       EmptySliceBehaviour
+	strictArgs        bool
+	hooks             []Hook
+	segments          []segment
+	expanding         bool
+	conditional       bool
+	repeat            *repeatGroup
 }
 
 // NewNamedTemplate creates a new NamedTemplate
 //
 // # Returns an error if the supplied template cannot be parsed for arg names
 //
-// Multiple options can be specified - each must be either a sqlnt.Option or sqlnt.TokenOption
+// Multiple options can be specified - each must be either a sqlnt.Option, sqlnt.TokenOption, sqlnt.RuntimeToken,
+// sqlnt.MarkerSyntax, sqlnt.EmptySliceBehaviour, sqlnt.UnknownArgsBehaviour, sqlnt.Hook or sqlnt.TemplateName
 func NewNamedTemplate(statement string, options ...any) (NamedTemplate, error) {
-	opt, tokenOptions, err := getOptions(options...)
+	opts, err := getOptions(options...)
 	if err != nil {
 		return nil, err
 	}
-	result := newNamedTemplate(statement, opt.UsePositionalTags(), opt.ArgTag(), tokenOptions)
-	if err = result.buildArgs(); err != nil {
-		return nil, err
+	result, err := newNamedTemplateFromOptions(statement, opts)
+	if err != nil {
+		return nil, result.namedError(err)
 	}
 	return result, nil
 }
@@ -127,17 +288,50 @@ func MustCreateNamedTemplate(statement string, options ...any) NamedTemplate {
 	return nt
 }
 
-func newNamedTemplate(statement string, usePositionalTags bool, argTag string, tokenOptions []TokenOption) *namedTemplate {
+func newNamedTemplateFromOptions(statement string, opts *templateOptions) (*namedTemplate, error) {
+	result := newNamedTemplate(statement, opts.option, opts.tokenOptions)
+	result.name = string(opts.name)
+	result.markers = opts.markers
+	result.emptySlices = opts.emptySlices
+	result.strictArgs = opts.unknownArgs.strict()
+	result.hooks = opts.hooks
+	result.runtime = newRuntimeTokens(opts.runtimeTokens)
+	return result, result.buildArgs()
+}
+
+func newNamedTemplate(statement string, option Option, tokenOptions []TokenOption) *namedTemplate {
 	return &namedTemplate{
 		originalStatement: statement,
 		args:              map[string]*namedArg{},
-		usePositionalTags: usePositionalTags,
-		argTag:            argTag,
+		option:            option,
+		usePositionalTags: option.UsePositionalTags(),
+		argTag:            option.ArgTag(),
+		preserveCasts:     preservesCasts(option),
+		backslashEscapes:  backslashEscapes(option),
+		hashComments:      hashComments(option),
+		formatter:         argTagFormatter(option),
+		literals:          literalFormatter(option),
+		maxArgs:           maxArgs(option),
 		tokenOptions:      tokenOptions,
 	}
 }
 
+// Name returns the name of the template - or empty if the template is not named
+//
+// Templates created by NewTemplateSet are named by their field path (e.g. "Users.Select") - otherwise templates
+// are named using the TemplateName option
+func (n *namedTemplate) Name() string {
+	return n.name
+}
+
 // Statement returns the sql statement to use (with named args transposed)
+//
+// NB. Where the template has expanding named args (e.g. `WHERE id IN (:ids...)`), the statement
+// has a single placeholder for each expanding arg - use StatementAndArgs to obtain the expanded statement
+//
+// Where the template has conditional fragments (e.g. `[[ AND status = :status ]]`), the statement includes all fragments
+//
+// Where the template has runtime tokens (see RuntimeToken), the statement contains the unreplaced tokens
 func (n *namedTemplate) Statement() string {
 	return n.statement
 }
@@ -145,19 +339,22 @@ func (n *namedTemplate) Statement() string {
 // StatementAndArgs returns the sql statement to use (with named args transposed) and
 // the input named args converted to positional args
 //
-// Essentially the same as calling Statement and then Args
+// Essentially the same as calling Statement and then Args - except where the template has expanding named args
+// (e.g. `WHERE id IN (:ids...)`), in which case the returned statement has a placeholder for each supplied slice item
+//
+// Conditional fragments (e.g. `[[ AND status = :status ]]`) are only included in the returned statement when all
+// the named args within the fragment are supplied
 func (n *namedTemplate) StatementAndArgs(args ...any) (string, []any, error) {
-	rargs, err := n.Args(args...)
-	return n.statement, rargs, err
+	return n.statementAndArgs(args...)
 }
 
 // MustStatementAndArgs is the same as StatementAndArgs, except no error is returned (and panics on error)
 func (n *namedTemplate) MustStatementAndArgs(args ...any) (string, []any) {
-	rargs, err := n.Args(args...)
+	statement, rargs, err := n.statementAndArgs(args...)
 	if err != nil {
 		panic(err)
 	}
-	return n.statement, rargs
+	return statement, rargs
 }
 
 // OriginalStatement returns the original named template statement
@@ -175,34 +372,66 @@ func (n *namedTemplate) OriginalStatement() string {
 //
 // * or any map where all keys are set as string
 //
-// * or anything that can be marshalled and then unmarshalled to map[string]any (such as structs!)
+// * a struct (or pointer to struct) - where each field is mapped to a named arg by its `db` tag, `json` tag or field name
+//
+// * or anything else that can be marshalled and then unmarshalled to map[string]any
 //
 // # If any of the named args specified in the query are missing, returns an error
 //
 // NB. named args are not considered missing when they have denoted as omissible (see NamedTemplate.OmissibleArgs) or
 // have been set with a default value (see NamedTemplate.DefaultValue)
+//
+// If the template was created with the UnknownArgsError option (or DefaultStrictArgs was set), returns an error if any
+// of the supplied args are not used by the template
+//
+// Where the template has expanding named args (e.g. `WHERE id IN (:ids...)`) or conditional fragments
+// (e.g. `[[ AND status = :status ]]`), the returned args are those for the statement returned by StatementAndArgs
 func (n *namedTemplate) Args(args ...any) ([]any, error) {
-	out := make([]any, n.argsCount)
+	_, out, err := n.statementAndArgs(args...)
+	return out, err
+}
+
+func (n *namedTemplate) statementAndArgs(args ...any) (string, []any, error) {
+	statement, out, err := n.resolveStatementAndArgs(args...)
+	return statement, out, n.namedError(err)
+}
+
+func (n *namedTemplate) resolveStatementAndArgs(args ...any) (string, []any, error) {
+	if n.runtime != nil {
+		v, err := n.variant(args...)
+		if err != nil {
+			return n.statement, nil, err
+		}
+		return v.resolveStatementAndArgs(args...)
+	}
 	mapped, err := mappedArgs(args...)
 	if err != nil {
-		return nil, err
+		return n.statement, nil, err
+	} else if err = n.checkUnknownArgs(mapped); err != nil {
+		return n.statement, nil, err
 	}
-	for name, arg := range n.args {
-		if v, ok := mapped[name]; ok {
-			av := arg.value(v)
-			for _, posn := range arg.positions {
-				out[posn] = av
-			}
-		} else if !arg.omissible {
-			return nil, fmt.Errorf("named arg '%s' missing", name)
-		} else if arg.defValue != nil {
-			v = arg.defaultedValue(name)
-			for _, posn := range arg.positions {
-				out[posn] = v
+	if n.expanding || n.conditional {
+		return n.render(mapped)
+	}
+	out := make([]any, n.argsCount)
+	missing := newMissingArgsError()
+	for _, name := range n.argNames {
+		arg := n.args[name]
+		v, err := arg.resolve(name, mapped)
+		if err != nil {
+			if missing.add(err) {
+				continue
 			}
+			return n.statement, nil, err
 		}
+		for _, posn := range arg.positions {
+			out[posn] = v
+		}
+	}
+	if err = missing.errorOrNil(); err != nil {
+		return n.statement, nil, err
 	}
-	return out, nil
+	return n.statement, out, nil
 }
 
 // MustArgs is the same as Args, except no error is returned (and panics on error)
@@ -219,7 +448,7 @@ func (n *namedTemplate) ArgsCount() int {
 	return n.argsCount
 }
 
-// OmissibleArgs specifies the names of args that can be omitted
+// OmissibleArgs returns a copy of the template where the specified names of args can be omitted
 //
 // # Calling this without any names makes all args omissible
 //
@@ -229,25 +458,26 @@ func (n *namedTemplate) ArgsCount() int {
 //
 // makes the named arg "b" omissible (denoted by the '?' after name)
 func (n *namedTemplate) OmissibleArgs(names ...string) NamedTemplate {
+	r := n.copy()
 	if len(names) == 0 {
-		for _, arg := range n.args {
+		for _, arg := range r.args {
 			arg.omissible = true
 		}
 	} else {
 		for _, name := range names {
-			if arg, ok := n.args[name]; ok {
+			if arg, ok := r.args[name]; ok {
 				arg.omissible = true
 			}
 		}
 	}
-	return n
+	return r
 }
 
 // DefaultValueFunc is the function signature for funcs that can be passed to
 // NamedTemplate.DefaultValue
 type DefaultValueFunc func(name string) any
 
-// DefaultValue specifies a value to be used for a given arg name when the arg
+// DefaultValue returns a copy of the template with a value to be used for a given arg name when the arg
 // is not supplied in the map for Args or MustArgs
 //
 // # Setting a default value for an arg name also makes that arg omissible
@@ -258,8 +488,10 @@ type DefaultValueFunc func(name string) any
 //
 // then that func is called to obtain the default value
 func (n *namedTemplate) DefaultValue(name string, v interface{}) NamedTemplate {
-	if arg, ok := n.args[name]; ok {
+	r := n.copy()
+	if arg, ok := r.args[name]; ok {
 		arg.omissible = true
+		arg.defSource = &defaultSource{value: v}
 		if dvf, ok := v.(func(name string) any); ok {
 			arg.defValue = dvf
 		} else {
@@ -268,18 +500,34 @@ func (n *namedTemplate) DefaultValue(name string, v interface{}) NamedTemplate {
 			}
 		}
 	}
-	return n
+	return r
 }
 
-// NullableStringArgs specifies the names of args that are nullable string
+// NullableStringArgs returns a copy of the template where the specified names of args are nullable string
 // i.e. where the value is an empty string, null is used instead
 func (n *namedTemplate) NullableStringArgs(names ...string) NamedTemplate {
+	r := n.copy()
 	for _, name := range names {
-		if arg, ok := n.args[name]; ok {
+		if arg, ok := r.args[name]; ok {
 			arg.nullableString = true
 		}
 	}
-	return n
+	return r
+}
+
+// ArgConverter returns a copy of the template with converters added for a given arg name - where each converter
+// is applied (in order) to the supplied (or default) value
+//
+// Converters already set for the arg name are retained (the supplied converters are applied after them)
+//
+// See NullableZeroConverter, TrimConverter, LowerCaseConverter, JsonConverter and EnumStringConverter
+// for built-in converters
+func (n *namedTemplate) ArgConverter(name string, converters ...ArgConverterFunc) NamedTemplate {
+	r := n.copy()
+	if arg, ok := r.args[name]; ok {
+		arg.converters = append(append([]ArgConverterFunc{}, arg.converters...), converters...)
+	}
+	return r
 }
 
 // GetArgNames returns a map of the arg names (where the map value is a bool indicating whether
@@ -304,18 +552,39 @@ func (n *namedTemplate) GetArgsInfo() map[string]ArgInfo {
 }
 
 // Clone clones the named template to another with a different option
+//
+// If the statement cannot be parsed under the option (e.g. `'a\'` is an unterminated string where the option recognises
+// backslash escapes), the returned template is an unchanged copy
 func (n *namedTemplate) Clone(option Option) NamedTemplate {
 	if option == nil {
 		option = DefaultsOption
 	}
-	if option.UsePositionalTags() == n.usePositionalTags && option.ArgTag() == n.argTag {
-		// no material change, just copy everything...
-		return n.copy()
+	if option.UsePositionalTags() == n.usePositionalTags && option.ArgTag() == n.argTag && preservesCasts(option) == n.preserveCasts &&
+		backslashEscapes(option) == n.backslashEscapes && hashComments(option) == n.hashComments &&
+		n.formatter == nil && argTagFormatter(option) == nil {
+		// no material change, just copy everything (except the option and what is derived from it)...
+		r := n.copy()
+		r.option = option
+		r.literals = literalFormatter(option)
+		r.maxArgs = maxArgs(option)
+		return r
 	} else {
-		r := newNamedTemplate(n.originalStatement, option.UsePositionalTags(), option.ArgTag(), n.tokenOptions)
-		_ = r.buildArgs()
+		r := newNamedTemplate(n.originalStatement, option, n.tokenOptions)
+		r.markers = n.markers
+		r.emptySlices = n.emptySlices
+		r.name = n.name
+		r.strictArgs = n.strictArgs
+		r.hooks = n.hooks
+		r.runtime = n.runtime.derive()
+		if err := r.buildArgs(); err != nil {
+			// the statement cannot be parsed under the option (e.g. backslash escapes leave a string unterminated)...
+			return n.copy()
+		}
 		for name, arg := range n.args {
-			arg.copyOptionsTo(r.args[name])
+			// the args may differ under the option (e.g. `\:a` is an escaped ':' when casts are preserved)...
+			if rarg, ok := r.args[name]; ok {
+				arg.copyOptionsTo(rarg)
+			}
 		}
 		return r
 	}
@@ -323,19 +592,10 @@ func (n *namedTemplate) Clone(option Option) NamedTemplate {
 
 // Append appends a statement portion to current statement and returns a new NamedTemplate
 //
-// Returns an error if the supplied statement portion cannot be parsed for arg names
+// The arg options (omissible, default values, converters etc.) of the template are preserved in the new template -
+// returns an error if the supplied statement portion cannot be parsed for arg names
 func (n *namedTemplate) Append(portion string) (NamedTemplate, error) {
-	result := newNamedTemplate(n.originalStatement+portion, n.usePositionalTags, n.argTag, n.tokenOptions)
-	if err := result.buildArgs(); err != nil {
-		return nil, err
-	}
-	for name, arg := range n.args {
-		if rarg, ok := result.args[name]; ok {
-			rarg.omissible = arg.omissible
-			rarg.defValue = arg.defValue
-		}
-	}
-	return result, nil
+	return n.Wrap("", portion)
 }
 
 // MustAppend is the same as Append, except no error is returned (and panics on error)
@@ -347,38 +607,107 @@ func (n *namedTemplate) MustAppend(portion string) NamedTemplate {
 	}
 }
 
-// Exec performs sql.DB.Exec on the supplied db with the supplied named args
-func (n *namedTemplate) Exec(db *sql.DB, args ...any) (sql.Result, error) {
-	if qargs, err := n.Args(args...); err == nil {
-		return db.Exec(n.statement, qargs...)
+// Prepend prepends a statement portion to current statement and returns a new NamedTemplate
+//
+// The arg options (omissible, default values, converters etc.) of the template are preserved in the new template -
+// returns an error if the supplied statement portion cannot be parsed for arg names
+func (n *namedTemplate) Prepend(portion string) (NamedTemplate, error) {
+	return n.Wrap(portion, "")
+}
+
+// MustPrepend is the same as Prepend, except no error is returned (and panics on error)
+func (n *namedTemplate) MustPrepend(portion string) NamedTemplate {
+	if result, err := n.Prepend(portion); err == nil {
+		return result
 	} else {
+		panic(err)
+	}
+}
+
+// Wrap surrounds the current statement with a prefix and suffix statement portion and returns a new NamedTemplate
+// (e.g. to wrap a query as a sub-select)
+//
+// The arg options (omissible, default values, converters etc.) of the template are preserved in the new template -
+// returns an error if the supplied statement portions cannot be parsed for arg names
+func (n *namedTemplate) Wrap(prefix string, suffix string) (NamedTemplate, error) {
+	result, err := compose(prefix+n.originalStatement+suffix, n)
+	if err != nil {
 		return nil, err
 	}
+	return result, nil
 }
 
-// ExecContext performs sql.DB.ExecContext on the supplied db with the supplied named args
-func (n *namedTemplate) ExecContext(ctx context.Context, db *sql.DB, args ...any) (sql.Result, error) {
-	if qargs, err := n.Args(args...); err == nil {
-		return db.ExecContext(ctx, n.statement, qargs...)
+// MustWrap is the same as Wrap, except no error is returned (and panics on error)
+func (n *namedTemplate) MustWrap(prefix string, suffix string) NamedTemplate {
+	if result, err := n.Wrap(prefix, suffix); err == nil {
+		return result
 	} else {
+		panic(err)
+	}
+}
+
+// Exec performs an exec on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+func (n *namedTemplate) Exec(db Execer, args ...any) (sql.Result, error) {
+	return n.ExecContext(context.Background(), db, args...)
+}
+
+// ExecContext performs an exec on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+func (n *namedTemplate) ExecContext(ctx context.Context, db Execer, args ...any) (sql.Result, error) {
+	statement, qargs, err := n.statementAndArgs(args...)
+	if err != nil {
 		return nil, err
 	}
+	event := n.newHookEvent(OpExec, statement, qargs)
+	err = n.execute(ctx, event, func(ctx context.Context) (err error) {
+		event.Result, err = db.ExecContext(ctx, event.Statement, event.Args...)
+		return
+	})
+	return event.Result, err
 }
 
-// Query performs sql.DB.Query on the supplied db with the supplied named args
-func (n *namedTemplate) Query(db *sql.DB, args ...any) (*sql.Rows, error) {
-	if qargs, err := n.Args(args...); err == nil {
-		return db.Query(n.statement, qargs...)
-	} else {
+// Query performs a query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+func (n *namedTemplate) Query(db Querier, args ...any) (*sql.Rows, error) {
+	return n.QueryContext(context.Background(), db, args...)
+}
+
+// QueryContext performs a query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+func (n *namedTemplate) QueryContext(ctx context.Context, db Querier, args ...any) (*sql.Rows, error) {
+	statement, qargs, err := n.statementAndArgs(args...)
+	if err != nil {
 		return nil, err
 	}
+	event := n.newHookEvent(OpQuery, statement, qargs)
+	err = n.execute(ctx, event, func(ctx context.Context) (err error) {
+		event.Rows, err = db.QueryContext(ctx, event.Statement, event.Args...)
+		return
+	})
+	return event.Rows, err
 }
 
-// QueryContext performs sql.DB.QueryContext on the supplied db with the supplied named args
-func (n *namedTemplate) QueryContext(ctx context.Context, db *sql.DB, args ...any) (*sql.Rows, error) {
-	if qargs, err := n.Args(args...); err == nil {
-		return db.QueryContext(ctx, n.statement, qargs...)
-	} else {
+// QueryRow performs a single row query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+//
+// Returns an error if the supplied named args cannot be converted - errors from the query itself
+// are deferred until sql.Row.Scan is called (as per sql.DB.QueryRow)
+func (n *namedTemplate) QueryRow(db RowQuerier, args ...any) (*sql.Row, error) {
+	return n.QueryRowContext(context.Background(), db, args...)
+}
+
+// QueryRowContext performs a single row query on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with the supplied named args
+//
+// Returns an error if the supplied named args cannot be converted - errors from the query itself
+// are deferred until sql.Row.Scan is called (as per sql.DB.QueryRowContext)
+func (n *namedTemplate) QueryRowContext(ctx context.Context, db RowQuerier, args ...any) (*sql.Row, error) {
+	statement, qargs, err := n.statementAndArgs(args...)
+	if err != nil {
+		return nil, err
+	}
+	event := n.newHookEvent(OpQueryRow, statement, qargs)
+	err = n.execute(ctx, event, func(ctx context.Context) error {
+		event.Row = db.QueryRowContext(ctx, event.Statement, event.Args...)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	return event.Row, nil
 }
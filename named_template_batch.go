@@ -0,0 +1,170 @@
+package sqlnt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Batch is a statement and args for a chunk of rows - as returned by NamedTemplate.Batches
+type Batch struct {
+	// Statement is the sql statement (with the repeatable group repeated for each row in the chunk)
+	Statement string
+	// Args is the positional args for the statement
+	Args []any
+	// Rows is the number of rows in the chunk
+	Rows int
+}
+
+var errNoRepeatableGroup = errors.New("template has no repeatable group")
+
+// Batches converts the supplied rows (a slice of maps/structs) into statements and args - where the repeatable group
+// (e.g. `VALUES (:a, :b)...`) is repeated for each row
+//
+// The supplied args are common to all rows (named args in each row take precedence)
+//
+// Rows are chunked into multiple batches where the number of args would exceed the max args (see MaxArgsOption)
+func (n *namedTemplate) Batches(rows any, args ...any) ([]Batch, error) {
+	batches, err := n.batches(rows, args...)
+	return batches, n.namedError(err)
+}
+
+func (n *namedTemplate) batches(rows any, args ...any) ([]Batch, error) {
+	if n.runtime != nil {
+		v, err := n.variant(args...)
+		if err != nil {
+			return nil, err
+		}
+		return v.batches(rows, args...)
+	}
+	if n.repeat == nil {
+		return nil, errNoRepeatableGroup
+	}
+	common, err := mappedArgs(args...)
+	if err != nil {
+		return nil, err
+	}
+	rowArgs, err := mappedRows(rows, common)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rowArgs {
+		if err = n.checkUnknownArgs(row); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	result := make([]Batch, 0)
+	if len(rowArgs) == 0 {
+		return result, nil
+	}
+	// determine how many rows can fit in each chunk...
+	_, fixedArgs, err := n.renderRows(common, nil)
+	if err != nil {
+		return nil, err
+	}
+	flush := func(chunk []map[string]any) error {
+		statement, qargs, err := n.renderRows(common, chunk)
+		if err == nil {
+			result = append(result, Batch{Statement: statement, Args: qargs, Rows: len(chunk)})
+		}
+		return err
+	}
+	start := 0
+	count := len(fixedArgs)
+	for i, row := range rowArgs {
+		rowCount, err := n.rowArgsCount(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		if n.maxArgs > 0 && i > start && count+rowCount > n.maxArgs {
+			if err = flush(rowArgs[start:i]); err != nil {
+				return nil, err
+			}
+			start = i
+			count = len(fixedArgs)
+		}
+		count += rowCount
+	}
+	if err = flush(rowArgs[start:]); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rowArgsCount determines the number of args the repeatable group renders for a row
+func (n *namedTemplate) rowArgsCount(row map[string]any) (int, error) {
+	r := n.newRenderer()
+	err := r.renderSegments(n.segments[n.repeat.start:n.repeat.end], r.newScope(row))
+	return len(r.out), err
+}
+
+// ExecBatch performs an exec of each batch (see Batches) on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn)
+func (n *namedTemplate) ExecBatch(db Execer, rows any, args ...any) (sql.Result, error) {
+	return n.ExecBatchContext(context.Background(), db, rows, args...)
+}
+
+// ExecBatchContext performs an exec of each batch (see Batches) on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn)
+func (n *namedTemplate) ExecBatchContext(ctx context.Context, db Execer, rows any, args ...any) (sql.Result, error) {
+	batches, err := n.Batches(rows, args...)
+	if err != nil {
+		return nil, err
+	}
+	result := &batchResult{results: make([]sql.Result, 0, len(batches))}
+	for _, batch := range batches {
+		event := n.newHookEvent(OpExecBatch, batch.Statement, batch.Args)
+		err = n.execute(ctx, event, func(ctx context.Context) (err error) {
+			event.Result, err = db.ExecContext(ctx, event.Statement, event.Args...)
+			return
+		})
+		if err != nil {
+			return result, err
+		}
+		result.results = append(result.results, event.Result)
+	}
+	return result, nil
+}
+
+// mappedRows converts each of the supplied rows (a slice of maps/structs) into mapped args - merged with the common mapped args
+func mappedRows(rows any, common map[string]any) ([]map[string]any, error) {
+	rv := reflect.ValueOf(rows)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, newArgsError("rows must be a slice")
+	}
+	result := make([]map[string]any, rv.Len())
+	for i := range result {
+		row, err := mappedArgs(common, rv.Index(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		result[i] = row
+	}
+	return result, nil
+}
+
+// batchResult is the sql.Result for NamedTemplate.ExecBatch - combining the results of each batch
+type batchResult struct {
+	results []sql.Result
+}
+
+// LastInsertId returns the last insert id of the last batch
+func (r *batchResult) LastInsertId() (int64, error) {
+	if l := len(r.results); l > 0 {
+		return r.results[l-1].LastInsertId()
+	}
+	return 0, nil
+}
+
+// RowsAffected returns the total rows affected by all batches
+func (r *batchResult) RowsAffected() (int64, error) {
+	total := int64(0)
+	for _, br := range r.results {
+		ra, err := br.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += ra
+	}
+	return total, nil
+}
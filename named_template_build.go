@@ -1,7 +1,6 @@
 package sqlnt
 
 import (
-	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -11,76 +10,300 @@ func (n *namedTemplate) buildArgs() error {
 	if err := n.replaceTokens(true); err != nil {
 		return err
 	}
+	err := n.parseStatement()
+	if perr, ok := err.(*ParseError); ok {
+		perr.locate(n.originalStatement)
+	}
+	return err
+}
+
+func (n *namedTemplate) parseStatement() error {
 	var builder strings.Builder
 	n.argsCount = 0
+	n.argNames = make([]string, 0)
+	n.nameSpans = make([]nameSpan, 0)
+	n.segments = make([]segment, 0)
+	n.expanding = false
+	n.repeat = nil
+	n.conditional = false
+	parens := make([]int, 0)
+	fragments := make([]int, 0)
+	fragmentPos, fragmentDepth, fragmentLen := -1, 0, 0
 	lastPos := 0
 	runes := []rune(n.originalStatement)
 	rlen := len(runes)
+	prefix, braced := n.markers.prefix(), n.markers.braced()
+	// doubled prefixes that are passed through untouched (Postgres `::` type casts or T-SQL `@@` globals) - where the
+	// prefix is escaped as `\:` or `\@`...
+	passDoubled := (prefix == ':' && n.preserveCasts) || prefix == '@'
 	purge := func(pos int) {
 		if pos > lastPos {
-			builder.WriteString(string(runes[lastPos:pos]))
+			s := string(runes[lastPos:pos])
+			builder.WriteString(s)
+			n.addTextSegment(s)
 		}
 	}
-	getNamed := func(pos int) (string, int, bool, error) {
-		i := pos + 1
+	revertFragment := func(closing string) {
+		// reverts the current conditional fragment back to text (as it was not a conditional fragment)...
+		start := fragments[len(fragments)-1]
+		fragments = fragments[:len(fragments)-1]
+		fragmentPos = -1
+		built := builder.String()
+		builder.Reset()
+		builder.WriteString(built[:fragmentLen] + "[[" + built[fragmentLen:] + closing)
+		n.segments[start] = segment{text: "[["}
+		if closing != "" {
+			n.addTextSegment(closing)
+		}
+		n.conditional = len(fragments) > 0
+	}
+	getSuffixes := func(i int, m *marker) int {
 		skip := 0
-		for ; i < rlen; i++ {
-			if !isNameRune(runes[i]) {
-				break
-			}
+		if (i+3) <= rlen && string(runes[i:i+3]) == "..." {
+			m.expand = true
+			skip += 3
+			i += 3
+		}
+		if i < rlen && runes[i] == '?' {
+			m.omissible = true
 			skip++
 		}
-		if skip == 0 {
-			return "", 0, false, fmt.Errorf("named marker ':' without name (at position %d)", pos)
+		return skip
+	}
+	getType := func(i int, m *marker) int {
+		// type annotation is an optional leading `[]` followed by letters - and must be a known type (otherwise it's not
+		// a type annotation - e.g. `:a:b` is two named args)...
+		if i < rlen && runes[i] == ':' {
+			j := i + 1
+			if (j+1) < rlen && runes[j] == '[' && runes[j+1] == ']' {
+				j += 2
+			}
+			k := j
+			for ; k < rlen && isTypeRune(runes[k]); k++ {
+			}
+			if typ := string(runes[i+1 : k]); k > j && isArgType(typ) {
+				m.typ = typ
+				return k - i
+			}
 		}
-		omissible := false
-		if (i+1) <= rlen && runes[i] == '?' {
-			omissible = true
-			skip++
+		return 0
+	}
+	getNamed := func(pos int) (marker, int, error) {
+		i := pos + 1
+		for ; i < rlen && isNameRune(runes[i]); i++ {
+		}
+		for ; (i-3) > pos && string(runes[i-3:i]) == "..."; i -= 3 {
+			// trailing '...' denotes expansion (rather than part of name)
+		}
+		if i == pos+1 {
+			return marker{}, 0, newParseError(pos, "named marker '%c' without name", prefix)
+		}
+		m := marker{name: string(runes[pos+1 : i]), span: nameSpan{start: pos + 1, end: i}}
+		tskip := getType(i, &m)
+		return m, i - pos - 1 + tskip + getSuffixes(i+tskip, &m), nil
+	}
+	getBracedNamed := func(pos int) (marker, int, error) {
+		i := pos + 2
+		for ; i < rlen && runes[i] != '}'; i++ {
+		}
+		if i == rlen {
+			return marker{}, 0, newParseError(pos, "named marker '%c{' without closing '}'", prefix)
+		}
+		m := marker{name: strings.TrimSpace(string(runes[pos+2 : i])), span: nameSpan{start: pos + 2, end: i}}
+		if m.name == "" {
+			return marker{}, 0, newParseError(pos, "named marker '%c{' without name", prefix)
 		}
-		return string(runes[pos+1 : i]), skip, omissible, nil
+		tskip := getType(i+1, &m)
+		return m, i - pos + tskip + getSuffixes(i+1+tskip, &m), nil
 	}
+	addNamed := func(pos int, getter func(pos int) (marker, int, error)) (int, error) {
+		purge(pos)
+		m, skip, err := getter(pos)
+		if err != nil {
+			return pos, err
+		}
+		tag, err := n.addNamedArg(m, pos)
+		if err != nil {
+			return pos, err
+		}
+		n.nameSpans = append(n.nameSpans, m.span)
+		pos += skip
+		lastPos = pos + 1
+		builder.WriteString(tag)
+		return pos, nil
+	}
+	var err error
 	for pos := 0; pos < rlen; pos++ {
-		if runes[pos] == ':' {
-			purge(pos)
-			if (pos+1) < rlen && runes[pos+1] == ':' {
-				// double escaped name marker...
+		if runes[pos] != prefix {
+			if passDoubled && runes[pos] == '\\' && (pos+1) < rlen && runes[pos+1] == prefix {
+				// escaped name marker (when doubled prefixes are passed through)...
+				purge(pos)
 				pos++
 				lastPos = pos
-			} else {
-				name, skip, omissible, err := getNamed(pos)
-				if err != nil {
+			} else if runes[pos] == '\\' && (pos+2) < rlen && runes[pos+1] == '[' && runes[pos+2] == '[' {
+				// escaped conditional fragment start (passed through as `[[`)...
+				purge(pos)
+				lastPos = pos + 1
+				pos += 2
+				if fragmentPos != -1 {
+					fragmentDepth += 2
+				}
+			} else if runes[pos] == '[' && (pos+1) < rlen && runes[pos+1] == '[' {
+				// start of a conditional fragment...
+				if fragmentPos != -1 {
+					return newParseError(pos, "nested conditional fragment")
+				}
+				purge(pos)
+				pos++
+				lastPos = pos + 1
+				fragmentPos, fragmentDepth, fragmentLen = pos-1, 0, builder.Len()
+				fragments = append(fragments, len(n.segments))
+				n.segments = append(n.segments, segment{fragment: true})
+				n.conditional = true
+			} else if runes[pos] == ']' && fragmentPos != -1 && fragmentDepth == 0 && (pos+1) < rlen && runes[pos+1] == ']' {
+				// end of a conditional fragment...
+				purge(pos)
+				pos++
+				lastPos = pos + 1
+				if start := fragments[len(fragments)-1]; hasNamedSegment(n.segments[start+1:]) {
+					fragmentPos = -1
+					n.segments[start].skip = len(n.segments) - start - 1
+				} else {
+					// no named args within - so not a conditional fragment (e.g. Postgres array subscript `arr[[1]]`)...
+					revertFragment("]]")
+				}
+			} else if fragmentPos != -1 && runes[pos] == '[' {
+				// brackets within a conditional fragment (e.g. `arr[idx[1]]`) must be balanced before the closing `]]`...
+				fragmentDepth++
+			} else if fragmentPos != -1 && runes[pos] == ']' {
+				if fragmentDepth > 0 {
+					fragmentDepth--
+				} else {
+					// unbalanced ']' - so not a conditional fragment (e.g. Postgres array literal `ARRAY[[1,2],[3,4]]`)...
+					revertFragment("")
+				}
+			} else if runes[pos] == '(' {
+				// may be the start of a repeatable group...
+				purge(pos)
+				lastPos = pos
+				parens = append(parens, len(n.segments))
+			} else if runes[pos] == ')' && len(parens) > 0 {
+				start := parens[len(parens)-1]
+				parens = parens[:len(parens)-1]
+				if (pos+3) < rlen && string(runes[pos+1:pos+4]) == "..." {
+					if n.repeat != nil {
+						return newParseError(pos, "only one repeatable group allowed")
+					} else if fragmentPos != -1 || crossesFragment(n.segments, fragments, start) {
+						return newParseError(pos, "repeatable group cannot overlap conditional fragment")
+					}
+					purge(pos + 1)
+					pos += 3
+					lastPos = pos + 1
+					n.repeat = &repeatGroup{start: start, end: len(n.segments)}
+				}
+			} else if end, ok, err := n.skipLiteral(runes, pos, prefix != '$' || braced); err != nil {
+				return err
+			} else if ok {
+				// string literals, quoted identifiers and comments are passed through untouched...
+				if prefix == ':' && !braced && !n.preserveCasts && runes[pos] != '$' {
+					// except that escaped `::` is still collapsed to ':' (as templates prior to literals being recognised needed to escape them)...
+					for i := pos; i < end; i++ {
+						if runes[i] == ':' && runes[i+1] == ':' {
+							purge(i)
+							lastPos = i + 1
+							i++
+						}
+					}
+				}
+				pos = end
+			}
+		} else if braced {
+			if (pos+2) < rlen && runes[pos+1] == prefix && runes[pos+2] == '{' {
+				// escaped name marker...
+				purge(pos)
+				pos++
+				lastPos = pos
+			} else if (pos+1) < rlen && runes[pos+1] == '{' {
+				if pos, err = addNamed(pos, getBracedNamed); err != nil {
 					return err
 				}
-				pos += skip
+			} else if end, ok, err := n.skipLiteral(runes, pos, true); err != nil {
+				return err
+			} else if ok {
+				// prefix not followed by brace - may be start of dollar-quoted string...
+				pos = end
+			}
+		} else if (pos+1) < rlen && runes[pos+1] == prefix {
+			purge(pos)
+			if passDoubled {
+				// type cast (or T-SQL global) - passed through untouched...
+				lastPos = pos
+			} else {
+				// double escaped name marker...
 				lastPos = pos + 1
-				builder.WriteString(n.addNamedArg(name, omissible))
 			}
+			pos++
+		} else if pos, err = addNamed(pos, getNamed); err != nil {
+			return err
 		}
 	}
+	if fragmentPos != -1 {
+		if hasNamedSegment(n.segments[fragments[len(fragments)-1]+1:]) {
+			return newParseError(fragmentPos, "conditional fragment '[[' without closing ']]'")
+		}
+		revertFragment("")
+	}
 	purge(rlen)
 	n.statement = builder.String()
 	return nil
 }
 
+// hasNamedSegment determines whether any of the segments is a named arg
+func hasNamedSegment(segments []segment) bool {
+	for _, seg := range segments {
+		if seg.name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// crossesFragment determines whether any of the (closed) conditional fragments starts before, but ends after, the segment start
+func crossesFragment(segments []segment, fragments []int, start int) bool {
+	for _, f := range fragments {
+		if f < start && f+segments[f].skip >= start {
+			return true
+		}
+	}
+	return false
+}
+
 var tokenRegexp = regexp.MustCompile(`\{\{([^}]*)}}`)
 
 func (n *namedTemplate) replaceTokens(first bool) error {
 	errs := make([]string, 0)
+	locations := make([]Location, 0)
+	matches := tokenRegexp.FindAllStringIndex(n.originalStatement, -1)
+	statement := n.originalStatement
+	i := -1
 	n.originalStatement = tokenRegexp.ReplaceAllStringFunc(n.originalStatement, func(s string) string {
+		i++
 		token := s[2 : len(s)-2]
+		if n.runtime.has(token) {
+			// runtime tokens are replaced at call time...
+			return s
+		}
 		for _, tr := range n.tokenOptions {
 			if r, ok := tr.Replace(token); ok {
 				return r
 			}
 		}
 		errs = append(errs, token)
+		locations = append(locations, locationOf(statement[:matches[i][0]]))
 		return ""
 	})
-	if len(errs) == 1 {
-		return fmt.Errorf("unknown token: %s", errs[0])
-	} else if len(errs) > 0 {
-		return fmt.Errorf("unknown tokens: %s", strings.Join(errs, ", "))
+	if len(errs) > 0 {
+		return &UnknownTokensError{Tokens: errs, Locations: locations}
 	}
 	if first && strings.Contains(n.originalStatement, "{{") && strings.Contains(n.originalStatement, "}}") {
 		return n.replaceTokens(false)
@@ -88,16 +311,152 @@ func (n *namedTemplate) replaceTokens(first bool) error {
 	return nil
 }
 
+// skipLiteral determines whether the rune at pos starts a string literal, quoted identifier, comment or
+// dollar-quoted string - and if so, returns the position of the last rune of it
+//
+// dollarQuotes is false when '$' is used as the named marker prefix (as dollar-quoted strings cannot then be distinguished)
+func (n *namedTemplate) skipLiteral(runes []rune, pos int, dollarQuotes bool) (int, bool, error) {
+	rlen := len(runes)
+	switch runes[pos] {
+	case '\'':
+		// Postgres E'...' strings allow backslash escapes (as do all strings where the option recognises them)...
+		escapable := n.backslashEscapes ||
+			(pos > 0 && (runes[pos-1] == 'E' || runes[pos-1] == 'e') && (pos == 1 || !isWordRune(runes[pos-2])))
+		if end, ok := skipQuoted(runes, pos, '\'', escapable); ok {
+			return end, true, nil
+		}
+		return 0, false, newParseError(pos, "unterminated quoted string")
+	case '"', '`':
+		// double-quoted strings allow backslash escapes where the option recognises them (e.g. MySql)...
+		if end, ok := skipQuoted(runes, pos, runes[pos], n.backslashEscapes && runes[pos] == '"'); ok {
+			return end, true, nil
+		}
+		return 0, false, newParseError(pos, "unterminated quoted identifier")
+	case '#':
+		if n.hashComments {
+			return skipLine(runes, pos+1), true, nil
+		}
+	case '-':
+		if pos+1 < rlen && runes[pos+1] == '-' {
+			return skipLine(runes, pos+2), true, nil
+		}
+	case '/':
+		if pos+1 < rlen && runes[pos+1] == '*' {
+			for end := pos + 3; end < rlen; end++ {
+				if runes[end] == '/' && runes[end-1] == '*' {
+					return end, true, nil
+				}
+			}
+			return 0, false, newParseError(pos, "unterminated comment")
+		}
+	case '$':
+		if tag, ok := dollarQuoteTag(runes, pos); ok && dollarQuotes {
+			tlen := len(tag)
+			for end := pos + tlen; end <= rlen-tlen; end++ {
+				if string(runes[end:end+tlen]) == tag {
+					return end + tlen - 1, true, nil
+				}
+			}
+			return 0, false, newParseError(pos, "unterminated dollar-quoted string")
+		}
+	}
+	return pos, false, nil
+}
+
+// skipLine returns the position of the last rune before the end of the line (for comments to end of line)
+func skipLine(runes []rune, pos int) int {
+	end := pos
+	for ; end < len(runes) && runes[end] != '\n'; end++ {
+	}
+	return end - 1
+}
+
+func skipQuoted(runes []rune, pos int, quote rune, escapable bool) (int, bool) {
+	rlen := len(runes)
+	for end := pos + 1; end < rlen; end++ {
+		if escapable && runes[end] == '\\' {
+			end++
+		} else if runes[end] == quote {
+			if end+1 < rlen && runes[end+1] == quote {
+				// doubled quote is an escaped quote...
+				end++
+			} else {
+				return end, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// dollarQuoteTag returns the Postgres dollar quote tag (e.g. "$$" or "$body$") starting at pos
+func dollarQuoteTag(runes []rune, pos int) (string, bool) {
+	rlen := len(runes)
+	if pos > 0 && isWordRune(runes[pos-1]) {
+		return "", false
+	}
+	for end := pos + 1; end < rlen; end++ {
+		if runes[end] == '$' {
+			return string(runes[pos : end+1]), true
+		} else if !isWordRune(runes[end]) || (end == pos+1 && runes[end] >= '0' && runes[end] <= '9') {
+			// tags cannot start with a digit (otherwise it's a positional arg - e.g. $1)
+			return "", false
+		}
+	}
+	return "", false
+}
+
 func isNameRune(r rune) bool {
 	return r == '_' || r == '-' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
 }
 
-func (n *namedTemplate) addNamedArg(name string, omissible bool) string {
+func isTypeRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// marker is a parsed named arg marker
+type marker struct {
+	name      string
+	omissible bool
+	expand    bool
+	typ       string
+	span      nameSpan
+}
+
+// nameSpan is the rune position (start inclusive, end exclusive) of a named arg marker name in the original statement
+type nameSpan struct {
+	start int
+	end   int
+}
+
+func (n *namedTemplate) addTextSegment(s string) {
+	n.segments = append(n.segments, segment{text: s})
+}
+
+func (n *namedTemplate) addNamedArg(m marker, pos int) (string, error) {
+	if arg, ok := n.args[m.name]; ok && m.typ != "" && arg.typ != "" && arg.typ != m.typ {
+		return "", newParseError(pos, "named arg '%s' has conflicting types '%s' and '%s'", m.name, arg.typ, m.typ)
+	}
+	n.segments = append(n.segments, segment{name: m.name, expand: m.expand})
+	if m.expand {
+		n.expanding = true
+	}
+	var tag string
 	if n.usePositionalTags {
-		return n.addNamedArgPositional(name, omissible)
+		tag = n.addNamedArgPositional(m.name, m.omissible)
 	} else {
-		return n.addNamedArgNonPositional(name, omissible)
+		tag = n.addNamedArgNonPositional(m.name, m.omissible)
 	}
+	if m.expand {
+		n.args[m.name].expand = true
+	}
+	if m.typ != "" {
+		n.args[m.name].typ = m.typ
+	}
+	return tag, nil
 }
 
 func (n *namedTemplate) addNamedArgPositional(name string, omissible bool) string {
@@ -105,7 +464,8 @@ func (n *namedTemplate) addNamedArgPositional(name string, omissible bool) strin
 		arg.setOmissible(omissible)
 		return arg.tag
 	} else {
-		tag := n.argTag + strconv.Itoa(n.argsCount+1)
+		tag := n.placeholder(n.argsCount+1, name)
+		n.argNames = append(n.argNames, name)
 		n.args[name] = &namedArg{
 			tag:       tag,
 			positions: []int{n.argsCount},
@@ -117,16 +477,28 @@ func (n *namedTemplate) addNamedArgPositional(name string, omissible bool) strin
 }
 
 func (n *namedTemplate) addNamedArgNonPositional(name string, omissible bool) string {
+	tag := n.placeholder(n.argsCount+1, name)
 	if arg, ok := n.args[name]; ok {
 		arg.setOmissible(omissible)
 		arg.positions = append(arg.positions, n.argsCount)
 	} else {
+		n.argNames = append(n.argNames, name)
 		n.args[name] = &namedArg{
-			tag:       n.argTag,
+			tag:       tag,
 			positions: []int{n.argsCount},
 			omissible: omissible,
 		}
 	}
 	n.argsCount++
+	return tag
+}
+
+// placeholder returns the final arg placeholder for the given position (1 based) and named arg
+func (n *namedTemplate) placeholder(position int, name string) string {
+	if n.formatter != nil {
+		return n.formatter.FormatArgTag(position, name)
+	} else if n.usePositionalTags {
+		return n.argTag + strconv.Itoa(position)
+	}
 	return n.argTag
 }
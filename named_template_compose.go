@@ -0,0 +1,236 @@
+package sqlnt
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	errConcatNoTemplates    = errors.New("no templates to concat")
+	errConcatMarkerSyntax   = errors.New("cannot concat templates with different marker syntax")
+	errConcatOptions        = errors.New("cannot concat templates with incompatible options")
+	errConcatImplementation = errors.New("cannot concat templates not created by sqlnt")
+	errEmbedPrefix          = errors.New("invalid embed prefix")
+)
+
+// Concat concatenates the statements of the supplied templates and returns a new NamedTemplate
+//
+// The new template has the option, marker syntax, name and other settings of the first template - the token options
+// and runtime tokens of all the templates are combined
+//
+// The arg options of each template are merged - where nullable string and redacted args are combined (i.e. set if
+// set in any template) - and default values and converters set differently for the same named arg in more than one
+// template are reported as a ConflictingArgsError (as is a named arg that is omissible in one template but required
+// in another - unless it has a default value)
+//
+// Default values are the same when they are equal values - but func default values and converters are only the same when
+// set by the same call (e.g. the same template concatenated more than once) - as funcs cannot be compared
+//
+// Returns an error if no templates are supplied, the templates have different marker syntax or incompatible options
+// (see compatible), the concatenated statement cannot be parsed for arg names or any named args have conflicting options
+func Concat(templates ...NamedTemplate) (NamedTemplate, error) {
+	if len(templates) == 0 {
+		return nil, errConcatNoTemplates
+	}
+	parts := make([]*namedTemplate, len(templates))
+	statement := ""
+	for i, template := range templates {
+		nt, ok := template.(*namedTemplate)
+		if !ok {
+			return nil, errConcatImplementation
+		} else if i > 0 && nt.markers != parts[0].markers {
+			return nil, errConcatMarkerSyntax
+		} else if i > 0 && !nt.compatible(parts[0]) {
+			return nil, errConcatOptions
+		}
+		parts[i] = nt
+		statement += nt.originalStatement
+	}
+	result, err := compose(statement, parts...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MustConcat is the same as Concat, except no error is returned (and panics on error)
+func MustConcat(templates ...NamedTemplate) NamedTemplate {
+	result, err := Concat(templates...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Embed appends the statement of the sub template - with its named args renamed to `prefix.name` - and returns
+// a new NamedTemplate
+//
+// The arg options of the sub template are preserved (for the renamed args) - and the embedded args can be supplied
+// as nested maps or structs (see dotted arg names) - example:
+//
+//	tenantFilter := sqlnt.MustCreateNamedTemplate(` AND tenant_id = :id`)
+//	tmp := sqlnt.MustCreateNamedTemplate(`SELECT * FROM users WHERE id = :id`).MustEmbed(tenantFilter, "tenant")
+//	args, err := tmp.Args(map[string]any{"id": 1, "tenant": map[string]any{"id": 2}})
+//
+// Returns an error if the prefix is not a valid arg name, the templates have different marker syntax or incompatible
+// options or the sub template args conflict with existing args (see Concat)
+func (n *namedTemplate) Embed(sub NamedTemplate, prefix string) (NamedTemplate, error) {
+	st, ok := sub.(*namedTemplate)
+	if !ok {
+		return nil, errConcatImplementation
+	} else if st.markers != n.markers {
+		return nil, errConcatMarkerSyntax
+	} else if !st.compatible(n) {
+		return nil, errConcatOptions
+	}
+	ns, err := st.namespaced(prefix)
+	if err != nil {
+		return nil, err
+	}
+	result, err := compose(n.originalStatement+ns.originalStatement, n, ns)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// MustEmbed is the same as Embed, except no error is returned (and panics on error)
+func (n *namedTemplate) MustEmbed(sub NamedTemplate, prefix string) NamedTemplate {
+	if result, err := n.Embed(sub, prefix); err == nil {
+		return result
+	} else {
+		panic(err)
+	}
+}
+
+// namespaced returns a copy of the template with each named arg renamed to `prefix.name` (and its arg options preserved)
+func (n *namedTemplate) namespaced(prefix string) (*namedTemplate, error) {
+	if !n.validPrefix(prefix) {
+		return nil, errEmbedPrefix
+	}
+	runes := []rune(n.originalStatement)
+	var builder strings.Builder
+	last := 0
+	for _, span := range n.nameSpans {
+		builder.WriteString(string(runes[last:span.start]))
+		builder.WriteString(prefix + "." + strings.TrimSpace(string(runes[span.start:span.end])))
+		last = span.end
+	}
+	builder.WriteString(string(runes[last:]))
+	r := n.derive(builder.String())
+	if err := r.buildArgs(); err != nil {
+		return nil, n.namedError(err)
+	}
+	for name, arg := range n.args {
+		if rarg, ok := r.args[prefix+"."+name]; ok {
+			arg.copyOptionsTo(rarg)
+		}
+	}
+	return r, nil
+}
+
+// compatible determines whether the template statement parses (and arg tags format) the same under the option of
+// the other template - i.e. the same cast, backslash escapes, hash comments and arg tag formatter settings
+func (n *namedTemplate) compatible(o *namedTemplate) bool {
+	return n.preserveCasts == o.preserveCasts && n.backslashEscapes == o.backslashEscapes &&
+		n.hashComments == o.hashComments && sameFormatter(n.formatter, o.formatter)
+}
+
+// sameFormatter determines whether arg tag formatters are the same (formatters of non-comparable types are never the same)
+func sameFormatter(f1 ArgTagFormatter, f2 ArgTagFormatter) bool {
+	if f1 == nil || f2 == nil {
+		return f1 == nil && f2 == nil
+	} else if reflect.TypeOf(f1) != reflect.TypeOf(f2) || !reflect.TypeOf(f1).Comparable() {
+		return false
+	}
+	return f1 == f2
+}
+
+// validPrefix determines whether the prefix can be used in named arg markers (for the marker syntax of the template)
+func (n *namedTemplate) validPrefix(prefix string) bool {
+	if prefix == "" || strings.HasPrefix(prefix, ".") || strings.HasSuffix(prefix, ".") || strings.Contains(prefix, "..") {
+		return false
+	}
+	for _, r := range prefix {
+		if n.markers.braced() {
+			if r == '}' {
+				return false
+			}
+		} else if !isNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// compose builds a new template from the statement - with the settings of the first part and the merged
+// token options, runtime tokens and arg options of all the parts
+func compose(statement string, parts ...*namedTemplate) (*namedTemplate, error) {
+	first := parts[0]
+	r := first.derive(statement)
+	if len(parts) > 1 {
+		r.tokenOptions = make([]TokenOption, 0)
+		runtimeTokens := make([]RuntimeToken, 0)
+		for _, part := range parts {
+			r.tokenOptions = append(r.tokenOptions, part.tokenOptions...)
+			if part.runtime != nil {
+				runtimeTokens = append(runtimeTokens, part.runtime.tokens...)
+			}
+		}
+		r.runtime = newRuntimeTokens(runtimeTokens)
+	}
+	if err := r.buildArgs(); err != nil {
+		return nil, r.namedError(err)
+	}
+	conflicts := newConflictingArgsError()
+	// whether each arg is marked omissible (`?`) in the statement - which includes any portions that are not parts...
+	marked := make(map[string]bool, len(r.args))
+	for _, part := range parts {
+		for name, arg := range part.args {
+			if rarg, ok := r.args[name]; ok {
+				if _, merging := marked[name]; merging {
+					conflicts.add(name, rarg.mergeOptions(arg))
+				} else {
+					marked[name] = rarg.omissible
+					arg.copyOptionsTo(rarg)
+				}
+			}
+		}
+	}
+	for name, omissible := range marked {
+		if omissible {
+			r.args[name].omissible = true
+		}
+	}
+	if err := conflicts.errorOrNil(); err != nil {
+		return nil, r.namedError(err)
+	}
+	return r, nil
+}
+
+func newConflictingArgsError() *ConflictingArgsError {
+	return &ConflictingArgsError{
+		Names:   make([]string, 0),
+		Options: map[string][]string{},
+	}
+}
+
+func (e *ConflictingArgsError) add(name string, options []string) {
+	if len(options) > 0 {
+		if _, ok := e.Options[name]; !ok {
+			e.Names = append(e.Names, name)
+		}
+		e.Options[name] = append(e.Options[name], options...)
+	}
+}
+
+// errorOrNil returns the error (or nil if there are no conflicting args)
+func (e *ConflictingArgsError) errorOrNil() error {
+	if len(e.Names) == 0 {
+		return nil
+	}
+	sort.Strings(e.Names)
+	return e
+}
@@ -0,0 +1,271 @@
+package sqlnt
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNamedTemplate_Append_PreservesOptions(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, TemplateName("Select")).
+		NullableStringArgs("a").
+		RedactedArgs("a").
+		ArgConverter("a", TrimConverter)
+	nt2 := nt.MustAppend(` AND col_b = :b?`)
+	info := nt2.GetArgsInfo()
+	assert.True(t, info["a"].NullableString)
+	assert.True(t, info["a"].Redacted)
+	assert.Equal(t, 1, len(info["a"].Converters))
+	assert.True(t, info["b"].Omissible)
+	assert.Equal(t, "Select", nt2.Name())
+	args, err := nt2.Args(map[string]any{"a": ""})
+	require.NoError(t, err)
+	assert.Equal(t, []any{nil, nil}, args)
+}
+
+func TestNamedTemplate_Prepend(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, PostgresOption).DefaultValue("a", "default a")
+	nt2, err := nt.Prepend(`WITH cte AS (SELECT :b AS b) `)
+	require.NoError(t, err)
+	assert.Equal(t, `WITH cte AS (SELECT $1 AS b) SELECT * FROM table WHERE col_a = $2`, nt2.Statement())
+	args, err := nt2.Args(map[string]any{"b": "bb"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"bb", "default a"}, args)
+
+	_, err = nt.Prepend(`: `)
+	assert.Error(t, err)
+	assert.Panics(t, func() {
+		_ = nt.MustPrepend(`: `)
+	})
+}
+
+func TestNamedTemplate_Wrap(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT id FROM table WHERE col_a = :a`).OmissibleArgs("a")
+	nt2 := nt.MustWrap(`SELECT COUNT(*) FROM (`, `) sub WHERE sub.id > :min`)
+	assert.Equal(t, `SELECT COUNT(*) FROM (SELECT id FROM table WHERE col_a = ?) sub WHERE sub.id > ?`, nt2.Statement())
+	assert.True(t, nt2.GetArgsInfo()["a"].Omissible)
+	assert.False(t, nt2.GetArgsInfo()["min"].Omissible)
+
+	_, err := nt.Wrap(`(`, `) :`)
+	assert.Error(t, err)
+	assert.Panics(t, func() {
+		_ = nt.MustWrap(`(`, `) :`)
+	})
+}
+
+func TestConcat(t *testing.T) {
+	sel := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, PostgresOption).
+		DefaultValue("a", "default a")
+	filter := MustCreateNamedTemplate(` AND tenant = :tenant AND col_a <> :a`, PostgresOption).
+		NullableStringArgs("a").
+		ArgConverter("tenant", LowerCaseConverter)
+	nt, err := Concat(sel, filter)
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1 AND tenant = $2 AND col_a <> $1`, nt.Statement())
+	info := nt.GetArgsInfo()
+	assert.True(t, info["a"].Omissible)
+	assert.NotNil(t, info["a"].DefaultValue)
+	assert.True(t, info["a"].NullableString)
+	assert.Equal(t, 1, len(info["tenant"].Converters))
+	args, err := nt.Args(map[string]any{"tenant": "ACME"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"default a", "acme"}, args)
+
+	// same options on the same arg do not conflict...
+	nt, err = Concat(sel, MustCreateNamedTemplate(` UNION `, PostgresOption), sel)
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1 UNION SELECT * FROM table WHERE col_a = $1`, nt.Statement())
+	nt = MustConcat(filter, filter)
+	assert.Equal(t, 1, len(nt.GetArgsInfo()["tenant"].Converters))
+}
+
+func TestConcat_TokensAndRuntimeTokens(t *testing.T) {
+	nt1 := MustCreateNamedTemplate(`SELECT * FROM {{table}} WHERE col_a = :a`, TokenOptionMap{"table": "foo"})
+	nt2 := MustCreateNamedTemplate(` ORDER BY {{sort}}`, AllowedValuesToken("sort", "col_a"))
+	nt, err := Concat(nt1, nt2)
+	require.NoError(t, err)
+	statement, args, err := nt.StatementAndArgs(map[string]any{"a": "aa"}, TokenValues{"sort": "col_a"})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM foo WHERE col_a = ? ORDER BY col_a`, statement)
+	assert.Equal(t, []any{"aa"}, args)
+}
+
+func TestConcat_Conflicts(t *testing.T) {
+	nt1 := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a AND col_b = :b`).
+		DefaultValue("a", "x").
+		ArgConverter("b", TrimConverter)
+	nt2 := MustCreateNamedTemplate(` AND col_c = :a`).DefaultValue("a", "y")
+	_, err := Concat(nt1, nt2)
+	require.Error(t, err)
+	assert.Equal(t, "named arg 'a' has conflicting options: default value", err.Error())
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+	var cerr *ConflictingArgsError
+	require.True(t, errors.As(err, &cerr))
+	assert.Equal(t, []string{"a"}, cerr.Names)
+
+	nt3 := MustCreateNamedTemplate(` AND col_c = :b AND col_d = :a`, TemplateName("Filter")).
+		DefaultValue("a", func(name string) any { return "z" }).
+		ArgConverter("b", LowerCaseConverter)
+	_, err = Concat(nt1, nt3)
+	require.Error(t, err)
+	assert.Equal(t, "named args have conflicting options: 'a' (default value), 'b' (converters)", err.Error())
+	_, err = Concat(nt3, nt1)
+	assert.Equal(t, "template 'Filter': named args have conflicting options: 'a' (default value), 'b' (converters)", err.Error())
+	assert.Panics(t, func() {
+		_ = MustConcat(nt1, nt3)
+	})
+
+	// funcs only match when set by the same call...
+	enum := func(prefix string) ArgConverterFunc {
+		return func(v any) (any, error) {
+			return prefix + v.(string), nil
+		}
+	}
+	dflt := func(v string) func(name string) any {
+		return func(name string) any {
+			return v
+		}
+	}
+	nt4 := MustCreateNamedTemplate(`SELECT :a`).ArgConverter("a", enum("x")).DefaultValue("a", dflt("x"))
+	nt5 := MustCreateNamedTemplate(` + :a`).ArgConverter("a", enum("y")).DefaultValue("a", dflt("y"))
+	_, err = Concat(nt4, nt5)
+	assert.Equal(t, "named arg 'a' has conflicting options: default value, converters", err.Error())
+	_, err = Concat(nt4, nt4.MustAppend(` + 1`))
+	assert.NoError(t, err)
+	_, err = Concat(nt1, MustCreateNamedTemplate(` AND col_c = :b`).ArgConverter("b", TrimConverter))
+	assert.Equal(t, "named arg 'b' has conflicting options: converters", err.Error())
+	_, err = Concat(nt1, MustCreateNamedTemplate(` AND col_c = :a`).DefaultValue("a", "x"))
+	assert.NoError(t, err)
+
+	// required and omissible conflict - unless there is a default value...
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a`), MustCreateNamedTemplate(` AND :a?`))
+	assert.Equal(t, "named arg 'a' has conflicting options: omissible", err.Error())
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a`).OmissibleArgs("a"), MustCreateNamedTemplate(` AND :a`))
+	assert.Equal(t, "named arg 'a' has conflicting options: omissible", err.Error())
+	nt, err := Concat(MustCreateNamedTemplate(`SELECT :a?`), MustCreateNamedTemplate(` AND :a`).OmissibleArgs())
+	require.NoError(t, err)
+	assert.True(t, nt.GetArgsInfo()["a"].Omissible)
+	nt, err = Concat(MustCreateNamedTemplate(`SELECT :a`), MustCreateNamedTemplate(` AND :a?`).DefaultValue("a", 1))
+	require.NoError(t, err)
+	args, err := nt.Args(map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{1, 1}, args)
+	// omissible markers in appended portions are not conflicts...
+	nt = MustCreateNamedTemplate(`SELECT :a`).MustAppend(` AND :a?`)
+	assert.True(t, nt.GetArgsInfo()["a"].Omissible)
+}
+
+type otherTemplate struct {
+	NamedTemplate
+}
+
+func TestConcat_Errors(t *testing.T) {
+	_, err := Concat()
+	assert.Error(t, err)
+	assert.Equal(t, "no templates to concat", err.Error())
+
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a`), MustCreateNamedTemplate(` + @b`, AtMarkers))
+	assert.Error(t, err)
+	assert.Equal(t, "cannot concat templates with different marker syntax", err.Error())
+
+	_, err = Concat(&otherTemplate{})
+	assert.Error(t, err)
+
+	// parts must parse the same under the option of the first...
+	_, err = Concat(MustCreateNamedTemplate(`SELECT * FROM table WHERE a = :a`), MustCreateNamedTemplate(` AND d = :d::date`, PostgresOption))
+	assert.Equal(t, "cannot concat templates with incompatible options", err.Error())
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a`), MustCreateNamedTemplate(` + :b`, MySqlOption))
+	assert.Equal(t, errConcatOptions, err)
+	nt, err := Concat(MustCreateNamedTemplate(`SELECT :a`, SqlServerOption), MustCreateNamedTemplate(` + :b`))
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT @p1 + @p2`, nt.Statement())
+	fo := &testFormatterOption{}
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a`, fo), MustCreateNamedTemplate(` + :b`))
+	assert.Equal(t, errConcatOptions, err)
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a`, fo), MustCreateNamedTemplate(` + :b`, &testFormatterOption{}))
+	assert.Equal(t, errConcatOptions, err)
+	nt, err = Concat(MustCreateNamedTemplate(`SELECT :a`, fo), MustCreateNamedTemplate(` + :b`, fo))
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT :a + :b`, nt.Statement())
+
+	_, err = Concat(MustCreateNamedTemplate(`SELECT :a:int`), MustCreateNamedTemplate(` + :a:string`))
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+}
+
+func TestNamedTemplate_Embed(t *testing.T) {
+	tenantFilter := MustCreateNamedTemplate(` AND tenant_id = :id AND status IN (:statuses...)[[ AND region = :region:string ]]`, PostgresOption).
+		DefaultValue("statuses", []string{"active"}).
+		ArgConverter("region", LowerCaseConverter)
+	nt := MustCreateNamedTemplate(`SELECT * FROM users WHERE id = :id`, PostgresOption, TemplateName("Users")).
+		MustEmbed(tenantFilter, "tenant")
+	assert.Equal(t, "Users", nt.Name())
+	assert.Equal(t, `SELECT * FROM users WHERE id = :id AND tenant_id = :tenant.id AND status IN (:tenant.statuses...)[[ AND region = :tenant.region:string ]]`, nt.OriginalStatement())
+	info := nt.GetArgsInfo()
+	assert.Equal(t, 4, len(info))
+	assert.True(t, info["tenant.statuses"].Omissible)
+	assert.True(t, info["tenant.statuses"].Expand)
+	assert.Equal(t, "string", info["tenant.region"].Type)
+	assert.Equal(t, 1, len(info["tenant.region"].Converters))
+
+	statement, args, err := nt.StatementAndArgs(map[string]any{"id": 1, "tenant": map[string]any{"id": 2, "region": "EU"}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM users WHERE id = $1 AND tenant_id = $2 AND status IN ($3) AND region = $4 `, statement)
+	assert.Equal(t, []any{1, 2, "active", "eu"}, args)
+
+	type tenant struct {
+		Id       int      `db:"id"`
+		Statuses []string `db:"statuses"`
+	}
+	_, args, err = nt.StatementAndArgs(struct {
+		Id     int    `db:"id"`
+		Tenant tenant `db:"tenant"`
+	}{Id: 1, Tenant: tenant{Id: 2, Statuses: []string{"a", "b"}}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{1, 2, "a", "b"}, args)
+
+	_, err = nt.Args(map[string]any{"id": 1})
+	assert.Equal(t, "template 'Users': named arg 'tenant.id' missing", err.Error())
+}
+
+func TestNamedTemplate_Embed_Nested(t *testing.T) {
+	inner := MustCreateNamedTemplate(`:{a}`, ColonBraceMarkers)
+	middle := MustCreateNamedTemplate(`SELECT :{ a }, `, ColonBraceMarkers).MustEmbed(inner, "in ner")
+	outer := MustCreateNamedTemplate(`WITH x AS (`, ColonBraceMarkers).MustEmbed(middle, "m").MustAppend(`) SELECT :{a}`)
+	assert.Equal(t, `WITH x AS (SELECT :{m.a}, :{m.in ner.a}) SELECT :{a}`, outer.OriginalStatement())
+	args, err := outer.Args(map[string]any{"a": 1, "m": map[string]any{"a": 2, "in ner": map[string]any{"a": 3}}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{2, 3, 1}, args)
+}
+
+func TestNamedTemplate_Embed_Errors(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM users WHERE id = :id`)
+	sub := MustCreateNamedTemplate(` AND x = :x`)
+	for _, prefix := range []string{"", ".a", "a.", "a..b", "a b", "a}"} {
+		_, err := nt.Embed(sub, prefix)
+		assert.Error(t, err, prefix)
+		assert.Equal(t, "invalid embed prefix", err.Error())
+	}
+	assert.Panics(t, func() {
+		_ = nt.MustEmbed(sub, "")
+	})
+	_, err := nt.Embed(MustCreateNamedTemplate(` AND x = @x`, AtMarkers), "s")
+	assert.Equal(t, "cannot concat templates with different marker syntax", err.Error())
+	_, err = nt.Embed(&otherTemplate{}, "s")
+	assert.Error(t, err)
+	_, err = nt.Embed(MustCreateNamedTemplate(` AND d = :d::date`, PostgresOption), "s")
+	assert.Equal(t, "cannot concat templates with incompatible options", err.Error())
+	_, err = MustCreateNamedTemplate(`SELECT * FROM users WHERE id = :id`, PostgresOption).Embed(sub, "s")
+	assert.Equal(t, errConcatOptions, err)
+
+	// conflicting options with existing args...
+	nt2 := MustCreateNamedTemplate(`SELECT :s.x`).DefaultValue("s.x", 1)
+	_, err = nt2.Embed(sub.DefaultValue("x", 2), "s")
+	assert.Equal(t, "named arg 's.x' has conflicting options: default value", err.Error())
+	nt3, err := nt2.Embed(sub, "s")
+	require.NoError(t, err)
+	args, err := nt3.Args(map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{1, 1}, args)
+}
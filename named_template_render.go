@@ -0,0 +1,183 @@
+package sqlnt
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// segment is a portion of a parsed statement - either text, a named arg or the start of a conditional fragment
+// (where skip is the number of segments that follow in the fragment)
+type segment struct {
+	text     string
+	name     string
+	expand   bool
+	fragment bool
+	skip     int
+}
+
+// repeatGroup denotes the segments (start inclusive, end exclusive) of a repeatable group - e.g. `VALUES (:a, :b)...`
+type repeatGroup struct {
+	start int
+	end   int
+}
+
+// render renders the statement and args for the supplied mapped args - where named args
+// denoted as expanding have a placeholder for each item of a supplied slice
+func (n *namedTemplate) render(mapped map[string]any) (string, []any, error) {
+	r := n.newRenderer()
+	if err := r.renderSegments(n.segments, r.newScope(mapped)); err != nil {
+		return n.statement, nil, err
+	}
+	return r.builder.String(), r.out, nil
+}
+
+// renderRows renders the statement and args for the supplied mapped args - where the repeatable
+// group is rendered for each of the supplied rows
+func (n *namedTemplate) renderRows(mapped map[string]any, rows []map[string]any) (string, []any, error) {
+	r := n.newRenderer()
+	scope := r.newScope(mapped)
+	if err := r.renderSegments(n.segments[:n.repeat.start], scope); err != nil {
+		return "", nil, err
+	}
+	for i, row := range rows {
+		if i > 0 {
+			r.builder.WriteString(", ")
+		}
+		if err := r.renderSegments(n.segments[n.repeat.start:n.repeat.end], r.newScope(row)); err != nil {
+			return "", nil, fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	if err := r.renderSegments(n.segments[n.repeat.end:], scope); err != nil {
+		return "", nil, err
+	}
+	return r.builder.String(), r.out, nil
+}
+
+type renderer struct {
+	template    *namedTemplate
+	builder     strings.Builder
+	out         []any
+	interpolate bool // whether named args are rendered as sql literals (rather than placeholders)
+}
+
+// renderScope is the supplied args (and resolved values & placeholders) for rendering segments
+type renderScope struct {
+	mapped map[string]any
+	values map[string]any
+	tags   map[segment]string
+}
+
+func (n *namedTemplate) newRenderer() *renderer {
+	return &renderer{
+		template: n,
+		out:      make([]any, 0, n.argsCount),
+	}
+}
+
+func (r *renderer) newScope(mapped map[string]any) *renderScope {
+	return &renderScope{
+		mapped: mapped,
+		values: map[string]any{},
+		tags:   map[segment]string{},
+	}
+}
+
+func (r *renderer) renderSegments(segments []segment, scope *renderScope) error {
+	n := r.template
+	missing := newMissingArgsError()
+	for i := 0; i < len(segments); i++ {
+		seg := segments[i]
+		if seg.fragment {
+			if supplied, err := scope.supplied(segments[i+1 : i+1+seg.skip]); err != nil {
+				return err
+			} else if !supplied {
+				i += seg.skip
+			}
+			continue
+		} else if seg.name == "" {
+			r.builder.WriteString(seg.text)
+			continue
+		} else if tag, ok := scope.tags[seg]; ok && n.usePositionalTags {
+			r.builder.WriteString(tag)
+			continue
+		}
+		v, err := scope.value(n, seg.name)
+		if err != nil {
+			if missing.add(err) {
+				// carry on to report all missing args...
+				continue
+			}
+			return err
+		}
+		items := []any{v}
+		if seg.expand {
+			if items, err = n.expandValue(seg.name, v); err != nil {
+				return err
+			}
+		}
+		var tag string
+		if r.interpolate {
+			if tag, err = n.literal(seg.name, items); err != nil {
+				return err
+			}
+		} else {
+			itemTags := make([]string, len(items))
+			for i, item := range items {
+				r.out = append(r.out, item)
+				itemTags[i] = n.placeholder(len(r.out), seg.name)
+			}
+			tag = strings.Join(itemTags, ", ")
+		}
+		scope.tags[seg] = tag
+		r.builder.WriteString(tag)
+	}
+	return missing.errorOrNil()
+}
+
+// supplied determines whether all the named args in the segments (of a conditional fragment) were supplied
+func (s *renderScope) supplied(segments []segment) (bool, error) {
+	for _, seg := range segments {
+		if seg.name != "" {
+			if _, ok, _, err := lookupArg(seg.name, s.mapped); err != nil || !ok {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func (s *renderScope) value(n *namedTemplate, name string) (any, error) {
+	if v, ok := s.values[name]; ok {
+		return v, nil
+	}
+	v, err := n.args[name].resolve(name, s.mapped)
+	if err == nil {
+		s.values[name] = v
+	}
+	return v, err
+}
+
+// expandValue returns the items of a supplied slice value (byte slices and driver.Valuer values are not expanded)
+func (n *namedTemplate) expandValue(name string, v any) ([]any, error) {
+	if v != nil {
+		if _, ok := v.(driver.Valuer); !ok {
+			if rv := reflect.ValueOf(v); (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+				l := rv.Len()
+				if l == 0 {
+					if n.emptySlices == EmptySliceError {
+						return nil, newArgsError("named arg '%s' is an empty slice", name)
+					}
+					return []any{nil}, nil
+				}
+				result := make([]any, l)
+				for i := range result {
+					result[i] = rv.Index(i).Interface()
+				}
+				return result, nil
+			}
+		}
+	}
+	return []any{v}, nil
+}
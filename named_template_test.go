@@ -3,11 +3,14 @@ package sqlnt
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -359,7 +362,7 @@ func TestNamedTemplate(t *testing.T) {
 			statement:          `INSERT INTO table (col_a, col_b, col_c) VALUES(:, :b, :c)`,
 			options:            []any{PostgresOption},
 			expectError:        true,
-			expectErrorMessage: "named marker ':' without name (at position 47)",
+			expectErrorMessage: `named marker ':' without name (at line 1, column 48: "INSERT INTO table (col_a, col_b, col_c) VALUES(:, :b, :c)")`,
 		},
 		{
 			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, '::bb', '::ccc')`,
@@ -375,6 +378,249 @@ func TestNamedTemplate(t *testing.T) {
 			expectArgNamesCount: 2,
 			expectArgNames:      []string{"a", "bb"},
 		},
+		{
+			statement:           `SELECT * FROM table WHERE note = 'at 10:30' AND col_a = :a`,
+			expectStatement:     `SELECT * FROM table WHERE note = 'at 10:30' AND col_a = ?`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE note = 'it''s :not' AND col_a = :a`,
+			expectStatement:     `SELECT * FROM table WHERE note = 'it''s :not' AND col_a = ?`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE note = E'it\'s :not' AND col_a = :a`,
+			expectStatement:     `SELECT * FROM table WHERE note = E'it\'s :not' AND col_a = ?`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT "weird:col", ` + "`other:col`" + ` FROM table WHERE col_a = :a`,
+			expectStatement:     `SELECT "weird:col", ` + "`other:col`" + ` FROM table WHERE col_a = ?`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement: `SELECT * FROM table -- see: docs
+WHERE col_a = :a /* also: here */ AND col_b = :b`,
+			expectStatement: `SELECT * FROM table -- see: docs
+WHERE col_a = ? /* also: here */ AND col_b = ?`,
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE col_a = :a -- see: docs`,
+			expectStatement:     `SELECT * FROM table WHERE col_a = ? -- see: docs`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `CREATE FUNCTION foo() RETURNS int AS $$ SELECT :x::int $$; SELECT $body$ :y $$ :z $body$, $1, :a`,
+			expectStatement:     `CREATE FUNCTION foo() RETURNS int AS $$ SELECT :x::int $$; SELECT $body$ :y $$ :z $body$, $1, ?`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE note = 'at 10::30' AND col_a = :a -- see:: docs`,
+			expectStatement:     `SELECT * FROM table WHERE note = 'at 10:30' AND col_a = ? -- see: docs`,
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE note = 'at 10::30' AND col_a = :a`,
+			expectStatement:     `SELECT * FROM table WHERE note = 'at 10::30' AND col_a = $1`,
+			options:             []any{PostgresOption},
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE note = 'it\'s :not' AND other = "say \"b:\"" AND col_a = :a`,
+			expectStatement:     `SELECT * FROM table WHERE note = 'it\'s :not' AND other = "say \"b:\"" AND col_a = ?`,
+			options:             []any{MySqlOption},
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement: `SELECT * FROM table # don't :x
+WHERE col_a = :a # see: docs`,
+			expectStatement: `SELECT * FROM table # don't :x
+WHERE col_a = ? # see: docs`,
+			options:             []any{MySqlOption},
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:          `SELECT * FROM table WHERE note = 'it\'s' AND col_a = :a`,
+			expectError:        true,
+			expectErrorMessage: `unterminated quoted string (at line 1, column 40: "SELECT * FROM table WHERE note = 'it\\'s' AND col_a = :a")`,
+		},
+		{
+			statement:           `SELECT col_a # col_b FROM table WHERE col_a = :a`,
+			expectStatement:     `SELECT col_a # col_b FROM table WHERE col_a = $1`,
+			options:             []any{PostgresOption},
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:          `SELECT * FROM table WHERE note = 'unterminated :a`,
+			expectError:        true,
+			expectErrorMessage: `unterminated quoted string (at line 1, column 34: "SELECT * FROM table WHERE note = 'unterminated :a")`,
+		},
+		{
+			statement:          `SELECT "unterminated FROM table WHERE col_a = :a`,
+			expectError:        true,
+			expectErrorMessage: `unterminated quoted identifier (at line 1, column 8: "SELECT \"unterminated FROM table WHERE col_a = :a")`,
+		},
+		{
+			statement:          `SELECT * FROM table /* unterminated :a`,
+			expectError:        true,
+			expectErrorMessage: `unterminated comment (at line 1, column 21: "SELECT * FROM table /* unterminated :a")`,
+		},
+		{
+			statement:          `SELECT $tag$ unterminated :a $other$`,
+			expectError:        true,
+			expectErrorMessage: `unterminated dollar-quoted string (at line 1, column 8: "SELECT $tag$ unterminated :a $other$")`,
+		},
+		{
+			statement:           `SELECT :id::uuid, created_at::date FROM table WHERE col_a = :a? AND col_b = :b?::int`,
+			expectStatement:     `SELECT $1::uuid, created_at::date FROM table WHERE col_a = $2 AND col_b = $3::int`,
+			options:             []any{PostgresOption},
+			expectArgsCount:     3,
+			expectArgNamesCount: 3,
+			expectArgNames:      []string{"id", "a", "b"},
+			expectOmissibleArgs: []string{"a", "b"},
+		},
+		{
+			statement:           `SELECT * FROM table WHERE col_a = :a AND col_b = '\:b' AND col_c = \:c`,
+			expectStatement:     `SELECT * FROM table WHERE col_a = $1 AND col_b = '\:b' AND col_c = :c`,
+			options:             []any{PostgresOption},
+			expectArgsCount:     1,
+			expectArgNamesCount: 1,
+			expectArgNames:      []string{"a"},
+		},
+		{
+			statement:           `SELECT :id::uuid FROM table WHERE col_a = \:a`,
+			expectStatement:     `SELECT ?:uuid FROM table WHERE col_a = \?`,
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"id", "a"},
+		},
+		{
+			statement:           `SELECT @@ROWCOUNT, @@IDENTITY, '@a', \@x FROM table WHERE col_a = @a AND col_b = @b? AND col_c = @a AND col_d = :d`,
+			expectStatement:     `SELECT @@ROWCOUNT, @@IDENTITY, '@a', @x FROM table WHERE col_a = ? AND col_b = ? AND col_c = ? AND col_d = :d`,
+			options:             []any{AtMarkers},
+			expectArgsCount:     3,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+			expectOmissibleArgs: []string{"b"},
+			inArgs:              []any{map[string]any{"a": "a value"}},
+			expectOutArgs:       []any{"a value", nil, "a value"},
+		},
+		{
+			statement:          `SELECT * FROM table WHERE col_a = @ AND col_b = @b`,
+			options:            []any{AtMarkers},
+			expectError:        true,
+			expectErrorMessage: `named marker '@' without name (at line 1, column 35: "SELECT * FROM table WHERE col_a = @ AND col_b = @b")`,
+		},
+		{
+			statement:           `SELECT $$, '$a' FROM table WHERE col_a = $a AND col_b = $b::int AND col_c = :c`,
+			expectStatement:     `SELECT $, '$a' FROM table WHERE col_a = $1 AND col_b = $2::int AND col_c = :c`,
+			options:             []any{DollarMarkers, PostgresOption},
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+		},
+		{
+			statement:           `SELECT ::{a}, ':{a}', created_at::date, 'at 10:30' FROM table WHERE col_a = :{a} AND col_b = :{ first name }? AND col_c = :{b:c}`,
+			expectStatement:     `SELECT :{a}, ':{a}', created_at::date, 'at 10:30' FROM table WHERE col_a = ? AND col_b = ? AND col_c = ?`,
+			options:             []any{ColonBraceMarkers},
+			expectArgsCount:     3,
+			expectArgNamesCount: 3,
+			expectArgNames:      []string{"a", "first name", "b:c"},
+			expectOmissibleArgs: []string{"first name"},
+			inArgs:              []any{map[string]any{"a": "a value", "b:c": "bc value"}},
+			expectOutArgs:       []any{"a value", nil, "bc value"},
+		},
+		{
+			statement:           `SELECT $${a}, $$ ${a} $$, $1 FROM table WHERE col_a = ${a} AND col_b = ${b}::int AND col_c = :c`,
+			expectStatement:     `SELECT ${a}, $$ ${a} $$, $1 FROM table WHERE col_a = $1 AND col_b = $2::int AND col_c = :c`,
+			options:             []any{DollarBraceMarkers, PostgresOption},
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+		},
+		{
+			statement:          `SELECT * FROM table WHERE col_a = :{ } AND col_b = :{b}`,
+			options:            []any{ColonBraceMarkers},
+			expectError:        true,
+			expectErrorMessage: `named marker ':{' without name (at line 1, column 35: "SELECT * FROM table WHERE col_a = :{ } AND col_b = :{b}")`,
+		},
+		{
+			statement:          `SELECT * FROM table WHERE col_a = ${a`,
+			options:            []any{DollarBraceMarkers},
+			expectError:        true,
+			expectErrorMessage: `named marker '${' without closing '}' (at line 1, column 35: "SELECT * FROM table WHERE col_a = ${a")`,
+		},
+		{
+			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			expectStatement:     `INSERT INTO table (col_a, col_b, col_c) VALUES(@p1, @p2, @p1)`,
+			options:             []any{SqlServerOption},
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+			inArgs:              []any{map[string]any{"a": "a value", "b": "b value"}},
+			expectOutArgs:       []any{"a value", "b value"},
+		},
+		{
+			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			expectStatement:     `INSERT INTO table (col_a, col_b, col_c) VALUES(:1, :2, :1)`,
+			options:             []any{OracleOption},
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+		},
+		{
+			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			expectStatement:     `INSERT INTO table (col_a, col_b, col_c) VALUES(?1, ?2, ?1)`,
+			options:             []any{SqliteOption},
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+		},
+		{
+			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			expectStatement:     `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			options:             []any{&testFormatterOption{positional: true}},
+			expectArgsCount:     2,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+			inArgs:              []any{map[string]any{"a": "a value", "b": "b value"}},
+			expectOutArgs:       []any{"a value", "b value"},
+		},
+		{
+			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			expectStatement:     `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
+			options:             []any{&testFormatterOption{positional: false}},
+			expectArgsCount:     3,
+			expectArgNamesCount: 2,
+			expectArgNames:      []string{"a", "b"},
+			inArgs:              []any{map[string]any{"a": "a value", "b": "b value"}},
+			expectOutArgs:       []any{"a value", "b value", "a value"},
+		},
 		{
 			statement:           `UPDATE table SET col_a = ::`,
 			expectStatement:     `UPDATE table SET col_a = :`,
@@ -440,13 +686,13 @@ func TestNamedTemplate(t *testing.T) {
 			statement:          `INSERT INTO {{unknownToken}} ({{cols}}) VALUES({{argA}},{{argB}},{{argC}})`,
 			options:            []any{PostgresOption, testTokenOption},
 			expectError:        true,
-			expectErrorMessage: "unknown token: unknownToken",
+			expectErrorMessage: "unknown token: unknownToken (at line 1, column 13)",
 		},
 		{
 			statement:          `INSERT INTO {{unknown token}} ({{another unknown}}) VALUES({{argA}},{{argB}},{{argC}})`,
 			options:            []any{PostgresOption, testTokenOption},
 			expectError:        true,
-			expectErrorMessage: "unknown tokens: unknown token, another unknown",
+			expectErrorMessage: "unknown tokens: unknown token (at line 1, column 13), another unknown (at line 1, column 32)",
 		},
 		{
 			statement:           `INSERT INTO table (col_a, col_b, col_c) VALUES(:a, :b, :a)`,
@@ -495,9 +741,9 @@ func TestNamedTemplate(t *testing.T) {
 					_ = MustCreateNamedTemplate(tc.statement, tc.options...)
 				})
 				if tc.omissibleArgs != nil {
-					nt.OmissibleArgs(tc.omissibleArgs...)
+					nt = nt.OmissibleArgs(tc.omissibleArgs...)
 				}
-				nt.NullableStringArgs(tc.nullableStringArgs...)
+				nt = nt.NullableStringArgs(tc.nullableStringArgs...)
 				if tc.expectOriginal == "" {
 					assert.Equal(t, tc.statement, nt.OriginalStatement())
 				} else {
@@ -585,7 +831,7 @@ func TestNamedTemplate_DefaultValue(t *testing.T) {
 	assert.Equal(t, now, args[1])
 
 	time.Sleep(50 * time.Millisecond) // wait for time to change!
-	nt.DefaultValue("crat", func(name string) any {
+	nt = nt.DefaultValue("crat", func(name string) any {
 		return time.Now()
 	})
 	args, err = nt.Args(map[string]any{
@@ -632,6 +878,84 @@ func TestNamedTemplate_Clone(t *testing.T) {
 	assert.False(t, info["b"].NullableString)
 }
 
+func TestNamedTemplate_Clone_PreserveCasts(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT :id::uuid FROM table WHERE col_a = :a`)
+	assert.Equal(t, `SELECT ?:uuid FROM table WHERE col_a = ?`, nt.Statement())
+
+	nt2 := nt.Clone(PostgresOption)
+	assert.Equal(t, `SELECT $1::uuid FROM table WHERE col_a = $2`, nt2.Statement())
+	nt2, err := nt2.Append(` AND col_b = :b::int`)
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT $1::uuid FROM table WHERE col_a = $2 AND col_b = $3::int`, nt2.Statement())
+
+	nt3 := nt2.Clone(MySqlOption)
+	assert.Equal(t, `SELECT ?:uuid FROM table WHERE col_a = ? AND col_b = ?:int`, nt3.Statement())
+}
+
+func TestNamedTemplate_Clone_MarkerSyntax(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = @a AND col_b = :b`, AtMarkers)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = ? AND col_b = :b`, nt.Statement())
+
+	nt2 := nt.Clone(PostgresOption)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1 AND col_b = :b`, nt2.Statement())
+	nt2 = nt2.MustAppend(` AND col_c = @c`)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1 AND col_b = :b AND col_c = $2`, nt2.Statement())
+}
+
+func TestNamedTemplate_Clone_ArgTagFormatter(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a AND col_b = :b`, SqlServerOption)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = @p1 AND col_b = @p2`, nt.Statement())
+
+	opt := &testFormatterOption{positional: true}
+	nt2 := nt.Clone(opt)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = :a AND col_b = :b`, nt2.Statement())
+	info := nt2.GetArgsInfo()
+	assert.Equal(t, ":a", info["a"].Tag)
+	assert.Equal(t, ":b", info["b"].Tag)
+	nt2 = nt2.Clone(opt)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = :a AND col_b = :b`, nt2.Statement())
+	nt2 = nt2.MustAppend(` AND col_c = :c`)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = :a AND col_b = :b AND col_c = :c`, nt2.Statement())
+}
+
+func TestNamedTemplate_Clone_UnparseableUnderOption(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT 'a\' , :b`).DefaultValue("b", "bb")
+	assert.Equal(t, `SELECT 'a\' , ?`, nt.Statement())
+
+	nt2 := nt.Clone(MySqlOption)
+	assert.Equal(t, `SELECT 'a\' , ?`, nt2.Statement())
+	args, err := nt2.Args(map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"bb"}, args)
+}
+
+func TestNamedTemplate_Clone_ArgsDifferUnderOption(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT :b, \:a`).DefaultValue("a", "aa").DefaultValue("b", "bb")
+	assert.Equal(t, `SELECT ?, \?`, nt.Statement())
+
+	nt2 := nt.Clone(PostgresOption)
+	assert.Equal(t, `SELECT $1, :a`, nt2.Statement())
+	args, err := nt2.Args(map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"bb"}, args)
+}
+
+func TestNamedTemplate_Clone_MaxArgs(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`, &testMaxArgsOption{max: 6})
+	rows := []map[string]any{{"a": "a1", "b": 1}, {"a": "a2", "b": 2}, {"a": "a3", "b": 3}}
+	batches, err := nt.Batches(rows)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(batches))
+
+	nt2 := nt.Clone(&testMaxArgsOption{max: 4})
+	assert.Equal(t, nt.Statement(), nt2.Statement())
+	batches, err = nt2.Batches(rows)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(batches))
+	assert.Equal(t, []any{"a1", 1, "a2", 2}, batches[0].Args)
+	assert.Equal(t, []any{"a3", 3}, batches[1].Args)
+}
+
 func TestNamedTemplate_Append(t *testing.T) {
 	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`).
 		OmissibleArgs("a")
@@ -746,3 +1070,1138 @@ func TestNamedTemplate_QueryContext(t *testing.T) {
 	})
 	assert.Error(t, err)
 }
+
+func TestNamedTemplate_ExpandingArgs(t *testing.T) {
+	testCases := []struct {
+		statement       string
+		options         []any
+		inArgs          []any
+		expectStatement string
+		expectOutArgs   []any
+		expectError     string
+	}{
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids...) AND col_a = :a`,
+			inArgs:          []any{map[string]any{"ids": []int{1, 2, 3}, "a": "a value"}},
+			expectStatement: `SELECT * FROM table WHERE id IN (?, ?, ?) AND col_a = ?`,
+			expectOutArgs:   []any{1, 2, 3, "a value"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids...) AND col_a = :a AND other_id IN (:ids...) AND col_b = :ids`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"ids": []string{"x", "y"}, "a": "a value"}},
+			expectStatement: `SELECT * FROM table WHERE id IN ($1, $2) AND col_a = $3 AND other_id IN ($1, $2) AND col_b = $4`,
+			expectOutArgs:   []any{"x", "y", "a value", []string{"x", "y"}},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids...) AND col_a = :a AND other_id IN (:ids...)`,
+			inArgs:          []any{map[string]any{"ids": [2]int{1, 2}, "a": "a value"}},
+			expectStatement: `SELECT * FROM table WHERE id IN (?, ?) AND col_a = ? AND other_id IN (?, ?)`,
+			expectOutArgs:   []any{1, 2, "a value", 1, 2},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids...)`,
+			options:         []any{SqlServerOption},
+			inArgs:          []any{map[string]any{"ids": 1}},
+			expectStatement: `SELECT * FROM table WHERE id IN (@p1)`,
+			expectOutArgs:   []any{1},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids...) AND data = :data...`,
+			inArgs:          []any{map[string]any{"ids": []int{}, "data": []byte("abc")}},
+			expectStatement: `SELECT * FROM table WHERE id IN (?) AND data = ?`,
+			expectOutArgs:   []any{nil, []byte("abc")},
+		},
+		{
+			statement:   `SELECT * FROM table WHERE id IN (:ids...)`,
+			options:     []any{EmptySliceError},
+			inArgs:      []any{map[string]any{"ids": []int{}}},
+			expectError: "named arg 'ids' is an empty slice",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE id IN (:ids...)`,
+			inArgs:      []any{map[string]any{}},
+			expectError: "named arg 'ids' missing",
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids...?)`,
+			inArgs:          []any{map[string]any{}},
+			expectStatement: `SELECT * FROM table WHERE id IN (?)`,
+			expectOutArgs:   []any{nil},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:{ids}...?) AND col_a = :{a}`,
+			options:         []any{ColonBraceMarkers, PostgresOption},
+			inArgs:          []any{map[string]any{"ids": []any{"x", 2}, "a": "a value"}},
+			expectStatement: `SELECT * FROM table WHERE id IN ($1, $2) AND col_a = $3`,
+			expectOutArgs:   []any{"x", 2, "a value"},
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			nt, err := NewNamedTemplate(tc.statement, tc.options...)
+			require.NoError(t, err)
+			stmt, args, err := nt.StatementAndArgs(tc.inArgs...)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+				_, err = nt.Args(tc.inArgs...)
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectStatement, stmt)
+				assert.Equal(t, tc.expectOutArgs, args)
+				args, err = nt.Args(tc.inArgs...)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectOutArgs, args)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_ExpandingArgs_Info(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE id IN (:ids...) AND col_a = :a AND col_b = :b...c`, PostgresOption)
+	assert.Equal(t, `SELECT * FROM table WHERE id IN ($1) AND col_a = $2 AND col_b = $3`, nt.Statement())
+	info := nt.GetArgsInfo()
+	assert.Equal(t, 3, len(info))
+	assert.True(t, info["ids"].Expand)
+	assert.False(t, info["a"].Expand)
+	assert.False(t, info["b...c"].Expand)
+
+	nt2 := nt.Clone(MySqlOption)
+	stmt, args := nt2.MustStatementAndArgs(map[string]any{"ids": []int{1, 2}, "a": "a value", "b...c": "bc value"})
+	assert.Equal(t, `SELECT * FROM table WHERE id IN (?, ?) AND col_a = ? AND col_b = ?`, stmt)
+	assert.Equal(t, []any{1, 2, "a value", "bc value"}, args)
+}
+
+func TestNamedTemplate_ExpandingArgs_Exec(t *testing.T) {
+	nt := MustCreateNamedTemplate(`DELETE FROM table WHERE id IN (:ids...)`, PostgresOption)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectExec(`DELETE FROM table WHERE id IN ($1, $2, $3)`).
+		WithArgs(1, 2, 3).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`DELETE FROM table WHERE id IN ($1, $2)`).
+		WithArgs(4, 5).
+		WillReturnRows()
+
+	_, err = nt.Exec(db, map[string]any{"ids": []int{1, 2, 3}})
+	assert.NoError(t, err)
+	_, err = nt.Query(db, map[string]any{"ids": []int{4, 5}})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamedTemplate_ConditionalFragments(t *testing.T) {
+	testCases := []struct {
+		statement       string
+		options         []any
+		inArgs          []any
+		expectStatement string
+		expectOutArgs   []any
+		expectError     string
+	}{
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND status = :status]][[ AND name = :name]]`,
+			inArgs:          []any{map[string]any{"status": "active", "name": "foo"}},
+			expectStatement: `SELECT * FROM table WHERE 1=1 AND status = ? AND name = ?`,
+			expectOutArgs:   []any{"active", "foo"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND status = :status]][[ AND name = :name]]`,
+			inArgs:          []any{map[string]any{"name": "foo"}},
+			expectStatement: `SELECT * FROM table WHERE 1=1 AND name = ?`,
+			expectOutArgs:   []any{"foo"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND status = :status]][[ AND name = :name]]`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"name": "foo"}},
+			expectStatement: `SELECT * FROM table WHERE 1=1 AND name = $1`,
+			expectOutArgs:   []any{"foo"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND status = :status]][[ AND name = :name]]`,
+			inArgs:          []any{map[string]any{}},
+			expectStatement: `SELECT * FROM table WHERE 1=1`,
+			expectOutArgs:   []any{},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE col_a = :a[[ AND col_b BETWEEN :from AND :to]] AND col_c = :a`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": "aa", "from": 1}},
+			expectStatement: `SELECT * FROM table WHERE col_a = $1 AND col_c = $1`,
+			expectOutArgs:   []any{"aa"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE col_a = :a[[ AND col_b BETWEEN :from AND :to]] AND col_c = :a`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": "aa", "from": 1, "to": 2}},
+			expectStatement: `SELECT * FROM table WHERE col_a = $1 AND col_b BETWEEN $2 AND $3 AND col_c = $1`,
+			expectOutArgs:   []any{"aa", 1, 2},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND status = :status]]`,
+			inArgs:          []any{map[string]any{"status": nil}},
+			expectStatement: `SELECT * FROM table WHERE 1=1 AND status = ?`,
+			expectOutArgs:   []any{nil},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND id IN (:ids...)]]`,
+			inArgs:          []any{map[string]any{"ids": []int{1, 2}}},
+			expectStatement: `SELECT * FROM table WHERE 1=1 AND id IN (?, ?)`,
+			expectOutArgs:   []any{1, 2},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND col_a = :filter.a]]`,
+			inArgs:          []any{map[string]any{"filter": map[string]any{"b": "bb"}}},
+			expectStatement: `SELECT * FROM table WHERE 1=1`,
+			expectOutArgs:   []any{},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE note = '[[ :a ]]'[[ AND col_b = ']]' AND col_c = :c]]`,
+			inArgs:          []any{map[string]any{"c": "cc"}},
+			expectStatement: `SELECT * FROM table WHERE note = '[[ :a ]]' AND col_b = ']]' AND col_c = ?`,
+			expectOutArgs:   []any{"cc"},
+		},
+		{
+			statement:       `SELECT ARRAY[[1,2],[3,4]], arr[[1]] FROM table WHERE col_a = :a[[ AND col_b = :b]]`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": "aa"}},
+			expectStatement: `SELECT ARRAY[[1,2],[3,4]], arr[[1]] FROM table WHERE col_a = $1`,
+			expectOutArgs:   []any{"aa"},
+		},
+		{
+			statement:       `SELECT ARRAY[[1,2],[3,4]] FROM table WHERE col_a = :a`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": "aa"}},
+			expectStatement: `SELECT ARRAY[[1,2],[3,4]] FROM table WHERE col_a = $1`,
+			expectOutArgs:   []any{"aa"},
+		},
+		{
+			statement:       `SELECT \[[:a, 2], [3, 4]] AS arr, ARRAY[[1 FROM table`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": 1}},
+			expectStatement: `SELECT [[$1, 2], [3, 4]] AS arr, ARRAY[[1 FROM table`,
+			expectOutArgs:   []any{1},
+		},
+		{
+			statement:       `SELECT ARRAY[[:a, 2], [3, 4]], ARRAY\[[:b]] FROM table`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": 1, "b": 2}},
+			expectStatement: `SELECT ARRAY[[$1, 2], [3, 4]], ARRAY[[$2]] FROM table`,
+			expectOutArgs:   []any{1, 2},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND arr[idx[1]] = :a AND m = \[[1,2],[3,4]] AND col_b = :b]]`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": "aa", "b": "bb"}},
+			expectStatement: `SELECT * FROM table WHERE 1=1 AND arr[idx[1]] = $1 AND m = [[1,2],[3,4]] AND col_b = $2`,
+			expectOutArgs:   []any{"aa", "bb"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE 1=1[[ AND arr[idx[1]] = :a AND col_b = :b]]`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"a": "aa"}},
+			expectStatement: `SELECT * FROM table WHERE 1=1`,
+			expectOutArgs:   []any{},
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :a[[ AND col_b = :b]]`,
+			inArgs:      []any{map[string]any{"b": "bb"}},
+			expectError: "named arg 'a' missing",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE 1=1[[ AND col_a = :a.b]]`,
+			inArgs:      []any{map[string]any{"a": "not a map"}},
+			expectError: "named arg 'a.b' cannot be resolved - 'a' is not a map or struct",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			nt, err := NewNamedTemplate(tc.statement, tc.options...)
+			require.NoError(t, err)
+			stmt, args, err := nt.StatementAndArgs(tc.inArgs...)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+				_, err = nt.Args(tc.inArgs...)
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectStatement, stmt)
+				assert.Equal(t, tc.expectOutArgs, args)
+				args, err = nt.Args(tc.inArgs...)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectOutArgs, args)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_ConditionalFragments_Statement(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a[[ AND col_b = :b]]`, PostgresOption)
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = $1 AND col_b = $2`, nt.Statement())
+	assert.Equal(t, 2, nt.ArgsCount())
+
+	nt2 := nt.Clone(MySqlOption)
+	stmt, args := nt2.MustStatementAndArgs(map[string]any{"a": "aa"})
+	assert.Equal(t, `SELECT * FROM table WHERE col_a = ?`, stmt)
+	assert.Equal(t, []any{"aa"}, args)
+}
+
+func TestNamedTemplate_ConditionalFragments_Errors(t *testing.T) {
+	testCases := []struct {
+		statement   string
+		expectError string
+	}{
+		{
+			statement:   `SELECT * FROM table WHERE 1=1[[ AND col_a = :a`,
+			expectError: `conditional fragment '[[' without closing ']]' (at line 1, column 30: "SELECT * FROM table WHERE 1=1[[ AND col_a = :a")`,
+		},
+		{
+			statement:   `SELECT * FROM table WHERE 1=1[[ AND col_a = :a [[ AND col_b = :b]] ]]`,
+			expectError: `nested conditional fragment (at line 1, column 48: "...FROM table WHERE 1=1[[ AND col_a = :a [[ AND col_b = :b]] ]]")`,
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES [[(:a, :b)...]]`,
+			expectError: `repeatable group cannot overlap conditional fragment (at line 1, column 49: "INSERT INTO table (col_a,col_b) VALUES [[(:a, :b)...]]")`,
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES ([[:a, :b]])...`,
+			expectError: "",
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES [[ (:a ]], :b)...`,
+			expectError: `repeatable group cannot overlap conditional fragment (at line 1, column 53: "INSERT INTO table (col_a,col_b) VALUES [[ (:a ]], :b)...")`,
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			_, err := NewNamedTemplate(tc.statement)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_TypedArgs(t *testing.T) {
+	testCases := []struct {
+		statement       string
+		options         []any
+		inArgs          []any
+		expectStatement string
+		expectOutArgs   []any
+		expectError     string
+	}{
+		{
+			statement:       `SELECT * FROM table WHERE age > :age:int AND created_at > :at:time`,
+			inArgs:          []any{map[string]any{"age": float64(18), "at": "2023-01-02T03:04:05Z"}},
+			expectStatement: `SELECT * FROM table WHERE age > ? AND created_at > ?`,
+			expectOutArgs:   []any{int64(18), time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id = :id:int::bigint AND uid = :uid::uuid`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"id": 1, "uid": "x"}},
+			expectStatement: `SELECT * FROM table WHERE id = $1::bigint AND uid = $2::uuid`,
+			expectOutArgs:   []any{int64(1), "x"},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id = ANY(:ids:[]int)`,
+			options:         []any{PostgresOption},
+			inArgs:          []any{map[string]any{"ids": []any{float64(1), float64(2)}}},
+			expectStatement: `SELECT * FROM table WHERE id = ANY($1)`,
+			expectOutArgs:   []any{[]int64{1, 2}},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE id IN (:ids:[]int...)`,
+			inArgs:          []any{map[string]any{"ids": []any{float64(1), float64(2)}}},
+			expectStatement: `SELECT * FROM table WHERE id IN (?, ?)`,
+			expectOutArgs:   []any{int64(1), int64(2)},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE col_a = :a:string? AND col_b = :b:bool`,
+			inArgs:          []any{map[string]any{"b": true}},
+			expectStatement: `SELECT * FROM table WHERE col_a = ? AND col_b = ?`,
+			expectOutArgs:   []any{nil, true},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE col_a = :{a}:float AND col_b = :{a}`,
+			options:         []any{ColonBraceMarkers},
+			inArgs:          []any{map[string]any{"a": 1}},
+			expectStatement: `SELECT * FROM table WHERE col_a = ? AND col_b = ?`,
+			expectOutArgs:   []any{float64(1), float64(1)},
+		},
+		{
+			statement:       `SELECT * FROM table WHERE col_a = @a:float`,
+			options:         []any{AtMarkers},
+			inArgs:          []any{map[string]any{"a": 1}},
+			expectStatement: `SELECT * FROM table WHERE col_a = ?`,
+			expectOutArgs:   []any{float64(1)},
+		},
+		{
+			statement:   `SELECT * FROM table WHERE age > :age:int`,
+			inArgs:      []any{map[string]any{"age": "18"}},
+			expectError: "named arg 'age' cannot be converted to int (from string)",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE id IN (:ids:[]int...)`,
+			inArgs:      []any{map[string]any{"ids": []any{"x"}}},
+			expectError: "named arg 'ids' cannot be converted to []int (from []interface {})",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			nt, err := NewNamedTemplate(tc.statement, tc.options...)
+			require.NoError(t, err)
+			stmt, args, err := nt.StatementAndArgs(tc.inArgs...)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+				_, err = nt.Args(tc.inArgs...)
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectStatement, stmt)
+				assert.Equal(t, tc.expectOutArgs, args)
+				args, err = nt.Args(tc.inArgs...)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectOutArgs, args)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_TypedArgs_Info(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE age > :age:int AND id IN (:ids:[]int...) AND col_a = :a AND age < :age`)
+	info := nt.GetArgsInfo()
+	assert.Equal(t, "int", info["age"].Type)
+	assert.Equal(t, "[]int", info["ids"].Type)
+	assert.True(t, info["ids"].Expand)
+	assert.Equal(t, "", info["a"].Type)
+
+	nt = nt.DefaultValue("age", float64(21))
+	args, err := nt.Args(map[string]any{"ids": []int{1}, "a": "aa"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(21), int64(1), "aa", int64(21)}, args)
+
+	nt2 := nt.Clone(PostgresOption)
+	assert.Equal(t, "int", nt2.GetArgsInfo()["age"].Type)
+}
+
+func TestNamedTemplate_TypedArgs_Errors(t *testing.T) {
+	_, err := NewNamedTemplate(`SELECT * FROM table WHERE age > :age:int AND age < :age:float`)
+	assert.Error(t, err)
+	assert.Equal(t, `named arg 'age' has conflicting types 'int' and 'float' (at line 1, column 52: "...ELECT * FROM table WHERE age > :age:int AND age < :age:float")`, err.Error())
+}
+
+func TestNamedTemplate_TypedArgs_NotTypes(t *testing.T) {
+	// unknown types are not type annotations...
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE age > :age:integer AND col_a = :a:b`)
+	assert.Equal(t, `SELECT * FROM table WHERE age > ?? AND col_a = ??`, nt.Statement())
+	info := nt.GetArgsInfo()
+	assert.Equal(t, 4, len(info))
+	assert.Equal(t, "", info["age"].Type)
+	assert.Equal(t, "", info["a"].Type)
+
+	nt = MustCreateNamedTemplate(`SELECT * FROM table WHERE age > :{age}:integer`, ColonBraceMarkers)
+	assert.Equal(t, `SELECT * FROM table WHERE age > ?:integer`, nt.Statement())
+	assert.Equal(t, "", nt.GetArgsInfo()["age"].Type)
+
+	// closing brackets are not part of the type...
+	nt = MustCreateNamedTemplate(`SELECT 1 WHERE 1=1[[ AND a = :a:int]] AND x = arr[:i:int] AND y = arr[:j:[]int]`, PostgresOption)
+	assert.Equal(t, `SELECT 1 WHERE 1=1 AND a = $1 AND x = arr[$2] AND y = arr[$3]`, nt.Statement())
+	info = nt.GetArgsInfo()
+	assert.Equal(t, "int", info["a"].Type)
+	assert.Equal(t, "int", info["i"].Type)
+	assert.Equal(t, "[]int", info["j"].Type)
+	stmt, args, err := nt.StatementAndArgs(map[string]any{"i": float64(1), "j": []int{2}})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT 1 WHERE 1=1 AND x = arr[$1] AND y = arr[$2]`, stmt)
+	assert.Equal(t, []any{int64(1), []int64{2}}, args)
+}
+
+func TestNamedTemplate_StrictArgs(t *testing.T) {
+	type args struct {
+		A      string `db:"a"`
+		Status string `db:"status"`
+	}
+	testCases := []struct {
+		statement   string
+		options     []any
+		inArgs      []any
+		expectError string
+	}{
+		{
+			statement: `SELECT * FROM table WHERE col_a = :a AND status = :status?`,
+			inArgs:    []any{map[string]any{"a": "aa", "stauts": "x"}},
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :a AND status = :status?`,
+			options:     []any{UnknownArgsError},
+			inArgs:      []any{map[string]any{"a": "aa", "stauts": "x"}},
+			expectError: "unknown arg: stauts",
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :a AND status = :status?`,
+			options:     []any{UnknownArgsError},
+			inArgs:      []any{map[string]any{"a": "aa", "stauts": "x"}, sql.Named("b", "bb")},
+			expectError: "unknown args: b, stauts",
+		},
+		{
+			statement: `SELECT * FROM table WHERE col_a = :a AND status = :status?`,
+			options:   []any{UnknownArgsError},
+			inArgs:    []any{args{A: "aa"}},
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :a`,
+			options:     []any{UnknownArgsError},
+			inArgs:      []any{args{A: "aa"}},
+			expectError: "unknown arg: status",
+		},
+		{
+			statement: `SELECT * FROM table WHERE col_a = :filter.a[[ AND status = :status]]`,
+			options:   []any{UnknownArgsError},
+			inArgs:    []any{map[string]any{"filter": map[string]any{"a": "aa", "b": "bb"}}},
+		},
+		{
+			statement:   `SELECT * FROM table WHERE col_a = :filter.a`,
+			options:     []any{UnknownArgsError},
+			inArgs:      []any{map[string]any{"filter": "x", "filt": "y"}},
+			expectError: "unknown arg: filt",
+		},
+		{
+			statement: `SELECT * FROM {{table}} WHERE col_a = :a`,
+			options:   []any{UnknownArgsError, IdentifierToken("table")},
+			inArgs:    []any{map[string]any{"a": "aa"}, TokenValues{"table": "foo"}},
+		},
+		{
+			statement: `SELECT * FROM table WHERE col_a = :a`,
+			options:   []any{UnknownArgsIgnore},
+			inArgs:    []any{map[string]any{"a": "aa", "b": "bb"}},
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			nt, err := NewNamedTemplate(tc.statement, tc.options...)
+			require.NoError(t, err)
+			_, _, err = nt.StatementAndArgs(tc.inArgs...)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+				_, err = nt.Args(tc.inArgs...)
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_StrictArgs_Default(t *testing.T) {
+	defer func() {
+		DefaultStrictArgs = false
+	}()
+	DefaultStrictArgs = true
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`)
+	nt2 := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`, UnknownArgsIgnore)
+	DefaultStrictArgs = false
+	nt3 := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a`)
+
+	_, err := nt.Args(map[string]any{"a": "aa", "b": "bb"})
+	assert.Error(t, err)
+	_, err = nt.Clone(PostgresOption).Args(map[string]any{"a": "aa", "b": "bb"})
+	assert.Error(t, err)
+	_, err = nt.MustAppend(` AND col_c = :c`).Args(map[string]any{"a": "aa", "b": "bb", "c": "cc"})
+	assert.Error(t, err)
+	_, err = nt2.Args(map[string]any{"a": "aa", "b": "bb"})
+	assert.NoError(t, err)
+	_, err = nt3.Args(map[string]any{"a": "aa", "b": "bb"})
+	assert.NoError(t, err)
+}
+
+func TestNamedTemplate_StrictArgs_Batches(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`, UnknownArgsError)
+	_, err := nt.Batches([]map[string]any{{"a": 1, "b": 2}, {"a": 1, "b": 2, "c": 3}})
+	assert.Error(t, err)
+	assert.Equal(t, "row 1: unknown arg: c", err.Error())
+	_, err = nt.Batches([]map[string]any{{"a": 1}}, map[string]any{"b": 2})
+	assert.NoError(t, err)
+}
+
+type testEmbedded struct {
+	EmbeddedA string `db:"emb_a"`
+	EmbeddedB string
+	Shadowed  string `db:"a"`
+}
+
+type TestEmbeddedPtr struct {
+	EmbeddedPtr string `db:"emb_ptr"`
+}
+
+type testValuer struct {
+	v string
+}
+
+func (v testValuer) Value() (driver.Value, error) {
+	return v.v, nil
+}
+
+func TestNamedTemplate_StructArgs(t *testing.T) {
+	now := time.Now()
+	str := "str value"
+	nt := MustCreateNamedTemplate(`INSERT INTO table VALUES(:a, :b, :c, :d, :e, :f, :G, :emb_a, :EmbeddedB, :emb_ptr, :h, :i)`).
+		OmissibleArgs("emb_ptr")
+	type args struct {
+		testEmbedded
+		*TestEmbeddedPtr
+		A  string    `db:"a" json:"not_a"`
+		B  time.Time `db:"b"`
+		C  int64     `json:"c"`
+		D  []byte    `db:"d,omitempty"`
+		E  *string   `db:"e"`
+		F  *string   `db:"f"`
+		G  testValuer
+		H  *testValuer `db:"h"`
+		I  bool        `db:"-" json:"i"`
+		Ig string      `json:"-"`
+		i  string
+	}
+	out, err := nt.Args(args{
+		testEmbedded: testEmbedded{EmbeddedA: "emb a value", EmbeddedB: "emb b value", Shadowed: "shadowed"},
+		A:            "a value",
+		B:            now,
+		C:            int64(1) << 60,
+		D:            []byte("d value"),
+		E:            &str,
+		G:            testValuer{"g value"},
+		H:            &testValuer{"h value"},
+	}, map[string]any{"i": true})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a value", now, int64(1) << 60, []byte("d value"), str, nil, testValuer{"g value"}, "emb a value", "emb b value", nil, &testValuer{"h value"}, true}, out)
+
+	out, err = nt.Args(&args{
+		TestEmbeddedPtr: &TestEmbeddedPtr{EmbeddedPtr: "emb ptr value"},
+		G:               testValuer{"g value"},
+	}, map[string]any{"i": false})
+	require.NoError(t, err)
+	assert.Equal(t, "emb ptr value", out[9])
+	assert.Equal(t, testValuer{"g value"}, out[6])
+
+	_, err = nt.Args((*args)(nil))
+	assert.Error(t, err)
+}
+
+func TestNamedTemplate_StructArgs_JsonOmitEmpty(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table VALUES(:status, :count, :Tags, :d, :ptr)`).
+		DefaultValue("status", "unknown").
+		DefaultValue("count", 1).
+		DefaultValue("Tags", "none").
+		OmissibleArgs("ptr")
+	type args struct {
+		Status string   `json:"status,omitempty"`
+		Count  int      `json:"count,omitempty"`
+		Tags   []string `json:",omitempty"`
+		D      string   `db:"d" json:"d,omitempty"`
+		Ptr    *string  `json:"ptr,omitempty"`
+	}
+	out, err := nt.Args(args{})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"unknown", 1, "none", "", nil}, out)
+
+	out, err = nt.Args(args{Status: "active", Count: 2, Tags: []string{"a"}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"active", 2, []string{"a"}, "", nil}, out)
+}
+
+func TestGetStructMapping_Cached(t *testing.T) {
+	type args struct {
+		A string `db:"a"`
+	}
+	m1 := getStructMapping(reflect.TypeOf(args{}))
+	m2 := getStructMapping(reflect.TypeOf(args{}))
+	assert.Same(t, m1, m2)
+	assert.Equal(t, []fieldMapping{{name: "a", index: []int{0}}}, m1.fields)
+}
+
+func TestNamedTemplate_DottedArgs(t *testing.T) {
+	type address struct {
+		City string `db:"city"`
+	}
+	type user struct {
+		Name    string            `db:"name"`
+		Address *address          `db:"address"`
+		Tags    map[string]string `db:"tags"`
+	}
+	const statement = `SELECT * FROM table WHERE name = :user.name AND city = :user.address.city AND tag = :user.tags.a AND flat = :flat.name`
+	testCases := []struct {
+		statement     string
+		inArgs        []any
+		expectOutArgs []any
+		expectError   string
+	}{
+		{
+			inArgs: []any{map[string]any{
+				"user": user{Name: "Bilbo", Address: &address{City: "Hobbiton"}, Tags: map[string]string{"a": "a tag"}},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectOutArgs: []any{"Bilbo", "Hobbiton", "a tag", "flat value"},
+		},
+		{
+			inArgs: []any{map[string]any{
+				"user": &user{Name: "Bilbo", Address: &address{City: "Hobbiton"}, Tags: map[string]string{"a": "a tag"}},
+			}, sql.Named("flat.name", "flat value")},
+			expectOutArgs: []any{"Bilbo", "Hobbiton", "a tag", "flat value"},
+		},
+		{
+			inArgs: []any{map[string]any{
+				"user": map[string]any{"name": "Bilbo", "address": map[string]any{"city": "Hobbiton"}, "tags": map[string]any{"a": "a tag"}},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectOutArgs: []any{"Bilbo", "Hobbiton", "a tag", "flat value"},
+		},
+		{
+			inArgs: []any{map[string]any{
+				"user": user{Name: "Bilbo", Tags: map[string]string{"a": "a tag"}},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectError: "named arg 'user.address.city' missing ('user.address' is nil)",
+		},
+		{
+			inArgs: []any{map[string]any{
+				"user": user{Name: "Bilbo", Address: &address{City: "Hobbiton"}, Tags: map[string]string{}},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectError: "named arg 'user.tags.a' missing ('a' not found in 'user.tags')",
+		},
+		{
+			inArgs: []any{map[string]any{
+				"user": map[string]any{"name": "Bilbo", "address": map[string]any{"city": "Hobbiton"}, "tags": map[string]any{"a": "a tag"}},
+				"flat": map[string]any{"other": "flat value"},
+			}},
+			expectError: "named arg 'flat.name' missing ('name' not found in 'flat')",
+		},
+		{
+			statement: `SELECT * FROM table WHERE name = :user.name`,
+			inArgs: []any{map[string]any{
+				"user": struct{}{},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectError: "named arg 'user.name' missing ('name' not found in 'user')",
+		},
+		{
+			inArgs: []any{map[string]any{
+				"user": map[string]any{"name": "Bilbo", "address": "not a map", "tags": map[string]any{"a": "a tag"}},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectError: "named arg 'user.address.city' cannot be resolved - 'user.address' is not a map or struct",
+		},
+		{
+			statement: `SELECT * FROM table WHERE name = :user.name`,
+			inArgs: []any{map[string]any{
+				"user": map[int]any{},
+				"flat": map[string]any{"name": "flat value"},
+			}},
+			expectError: "named arg 'user.name' cannot be resolved - 'user' is not a map with string keys",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]", i+1), func(t *testing.T) {
+			stmt := statement
+			if tc.statement != "" {
+				stmt = tc.statement
+			}
+			out, err := MustCreateNamedTemplate(stmt).Args(tc.inArgs...)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectOutArgs, out)
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_DottedArgs_Omissible(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE city = :user.address.city? AND name = :user.name`).
+		DefaultValue("user.name", "unknown")
+	out, err := nt.Args(map[string]any{"user": map[string]any{"address": nil}})
+	require.NoError(t, err)
+	assert.Equal(t, []any{nil, "unknown"}, out)
+}
+
+func TestNamedTemplate_Exec_TxAndConn(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table (col_a, col_b) VALUES (:a, :b)`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectBegin()
+	mock.ExpectExec(nt.Statement()).
+		WithArgs("aa", "bb").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(nt.Statement()).
+		WithArgs("aa", "bb").
+		WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+	mock.ExpectCommit()
+	mock.ExpectExec(nt.Statement()).
+		WithArgs("cc", "dd").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(nt.Statement()).
+		WithArgs("cc", "dd").
+		WillReturnRows(sqlmock.NewRows([]string{"col_a"}))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = nt.Exec(tx, map[string]any{"a": "aa", "b": "bb"})
+	assert.NoError(t, err)
+	rows, err := nt.Query(tx, map[string]any{"a": "aa", "b": "bb"})
+	assert.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.NoError(t, tx.Commit())
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	_, err = nt.ExecContext(context.Background(), conn, map[string]any{"a": "cc", "b": "dd"})
+	assert.NoError(t, err)
+	rows, err = nt.QueryContext(context.Background(), conn, map[string]any{"a": "cc", "b": "dd"})
+	assert.NoError(t, err)
+	require.NoError(t, rows.Close())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNamedTemplate_QueryRow(t *testing.T) {
+	nt, err := NewNamedTemplate(`SELECT col_b FROM table WHERE col_a = :a`)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).
+		WithArgs("aa").
+		WillReturnRows(sqlmock.NewRows([]string{"col_b"}).AddRow("bb"))
+
+	row, err := nt.QueryRow(db, map[string]any{
+		"a": "aa",
+	})
+	require.NoError(t, err)
+	var b string
+	assert.NoError(t, row.Scan(&b))
+	assert.Equal(t, "bb", b)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = nt.QueryRow(db, map[string]any{})
+	assert.Error(t, err)
+}
+
+func TestNamedTemplate_QueryRowContext(t *testing.T) {
+	nt, err := NewNamedTemplate(`SELECT col_b FROM table WHERE col_a = :a`)
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).
+		WithArgs("aa").
+		WillReturnRows(sqlmock.NewRows([]string{"col_b"}))
+
+	row, err := nt.QueryRowContext(context.Background(), db, map[string]any{
+		"a": "aa",
+	})
+	require.NoError(t, err)
+	var b string
+	assert.ErrorIs(t, row.Scan(&b), sql.ErrNoRows)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = nt.QueryRowContext(context.Background(), db, map[string]any{})
+	assert.Error(t, err)
+}
+
+func TestNamedTemplate_Batches(t *testing.T) {
+	type row struct {
+		A string `db:"a"`
+		B int    `db:"b"`
+	}
+	rows := []any{
+		map[string]any{"a": "a1", "b": 1},
+		row{A: "a2", B: 2},
+		&row{A: "a3", B: 3},
+	}
+	testCases := []struct {
+		statement     string
+		option        Option
+		args          []any
+		expectStmts   []string
+		expectArgs    [][]any
+		expectRows    []int
+		expectErr     string
+		expectNoGroup bool
+	}{
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`,
+			expectStmts: []string{`INSERT INTO table (col_a,col_b) VALUES (?, ?), (?, ?), (?, ?)`},
+			expectArgs:  [][]any{{"a1", 1, "a2", 2, "a3", 3}},
+			expectRows:  []int{3},
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`,
+			option:      PostgresOption,
+			expectStmts: []string{`INSERT INTO table (col_a,col_b) VALUES ($1, $2), ($3, $4), ($5, $6)`},
+			expectArgs:  [][]any{{"a1", 1, "a2", 2, "a3", 3}},
+			expectRows:  []int{3},
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b,col_c) VALUES (:a, :b, :c)... ON CONFLICT DO UPDATE SET col_c = :c`,
+			option:      PostgresOption,
+			args:        []any{map[string]any{"c": "cc"}},
+			expectStmts: []string{`INSERT INTO table (col_a,col_b,col_c) VALUES ($1, $2, $3), ($4, $5, $6), ($7, $8, $9) ON CONFLICT DO UPDATE SET col_c = $10`},
+			expectArgs:  [][]any{{"a1", 1, "cc", "a2", 2, "cc", "a3", 3, "cc", "cc"}},
+			expectRows:  []int{3},
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`,
+			option:      &testMaxArgsOption{max: 4},
+			expectStmts: []string{`INSERT INTO table (col_a,col_b) VALUES ($1, $2), ($3, $4)`, `INSERT INTO table (col_a,col_b) VALUES ($1, $2)`},
+			expectArgs:  [][]any{{"a1", 1, "a2", 2}, {"a3", 3}},
+			expectRows:  []int{2, 1},
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`,
+			option:      &testMaxArgsOption{max: 1},
+			expectStmts: []string{`INSERT INTO table (col_a,col_b) VALUES ($1, $2)`, `INSERT INTO table (col_a,col_b) VALUES ($1, $2)`, `INSERT INTO table (col_a,col_b) VALUES ($1, $2)`},
+			expectArgs:  [][]any{{"a1", 1}, {"a2", 2}, {"a3", 3}},
+			expectRows:  []int{1, 1, 1},
+		},
+		{
+			statement:   `INSERT INTO table (col_a,col_b) VALUES (:a, (:b))...`,
+			expectStmts: []string{`INSERT INTO table (col_a,col_b) VALUES (?, (?)), (?, (?)), (?, (?))`},
+			expectArgs:  [][]any{{"a1", 1, "a2", 2, "a3", 3}},
+			expectRows:  []int{3},
+		},
+		{
+			statement: `INSERT INTO table (col_a,col_b) VALUES (:a, :b, :c)...`,
+			expectErr: "row 0: named arg 'c' missing",
+		},
+		{
+			statement: `INSERT INTO table (col_a,col_b) VALUES (:a, :b)... RETURNING :c`,
+			expectErr: "named arg 'c' missing",
+		},
+		{
+			statement:     `INSERT INTO table (col_a,col_b) VALUES (:a, :b)`,
+			expectNoGroup: true,
+			expectErr:     "template has no repeatable group",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			var options []any
+			if tc.option != nil {
+				options = append(options, tc.option)
+			}
+			nt, err := NewNamedTemplate(tc.statement, options...)
+			require.NoError(t, err)
+			batches, err := nt.Batches(rows, tc.args...)
+			if tc.expectErr != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectErr, err.Error())
+				if tc.expectNoGroup {
+					assert.ErrorIs(t, err, errNoRepeatableGroup)
+				}
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, len(tc.expectStmts), len(batches))
+				for b, batch := range batches {
+					assert.Equal(t, tc.expectStmts[b], batch.Statement)
+					assert.Equal(t, tc.expectArgs[b], batch.Args)
+					assert.Equal(t, tc.expectRows[b], batch.Rows)
+				}
+			}
+		})
+	}
+}
+
+func TestNamedTemplate_Batches_SingleRowStatement(t *testing.T) {
+	nt, err := NewNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`, PostgresOption)
+	require.NoError(t, err)
+	assert.Equal(t, `INSERT INTO table (col_a,col_b) VALUES ($1, $2)`, nt.Statement())
+	args, err := nt.Args(map[string]any{"a": "aa", "b": "bb"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"aa", "bb"}, args)
+}
+
+func TestNamedTemplate_Batches_NoRows(t *testing.T) {
+	nt, err := NewNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`)
+	require.NoError(t, err)
+	batches, err := nt.Batches([]map[string]any{})
+	require.NoError(t, err)
+	assert.Empty(t, batches)
+}
+
+func TestNamedTemplate_Batches_Errors(t *testing.T) {
+	_, err := NewNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)..., (:c)...`)
+	assert.Error(t, err)
+	assert.Equal(t, `only one repeatable group allowed (at line 1, column 56: "INSERT INTO table (col_a,col_b) VALUES (:a, :b)..., (:c)...")`, err.Error())
+
+	nt, err := NewNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`)
+	require.NoError(t, err)
+	_, err = nt.Batches("not a slice")
+	assert.Error(t, err)
+	assert.Equal(t, "rows must be a slice", err.Error())
+	_, err = nt.Batches([]any{map[int]any{1: "a"}})
+	assert.Error(t, err)
+	assert.Equal(t, "row 0: invalid map - keys must be string", err.Error())
+	_, err = nt.Batches([]any{}, map[int]any{1: "a"})
+	assert.Error(t, err)
+}
+
+func TestNamedTemplate_ExecBatch(t *testing.T) {
+	nt, err := NewNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)...`, &testMaxArgsOption{max: 4})
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectExec(`INSERT INTO table (col_a,col_b) VALUES ($1, $2), ($3, $4)`).
+		WithArgs("a1", "b1", "a2", "b2").
+		WillReturnResult(sqlmock.NewResult(2, 2))
+	mock.ExpectExec(`INSERT INTO table (col_a,col_b) VALUES ($1, $2)`).
+		WithArgs("a3", "b3").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	result, err := nt.ExecBatch(db, []map[string]any{
+		{"a": "a1", "b": "b1"},
+		{"a": "a2", "b": "b2"},
+		{"a": "a3", "b": "b3"},
+	})
+	require.NoError(t, err)
+	ra, err := result.RowsAffected()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), ra)
+	id, err := result.LastInsertId()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = nt.ExecBatch(db, []map[string]any{{}})
+	assert.Error(t, err)
+
+	mock.ExpectExec(`INSERT INTO table (col_a,col_b) VALUES ($1, $2)`).
+		WithArgs("a1", "b1").
+		WillReturnError(errors.New("fooey"))
+	_, err = nt.ExecBatchContext(context.Background(), db, []map[string]any{{"a": "a1", "b": "b1"}})
+	assert.Error(t, err)
+	assert.Equal(t, "fooey", err.Error())
+}
+
+func TestNamedTemplate_Name(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM table WHERE col_a = :a AND col_b = :b`, TemplateName("Select"))
+	assert.Equal(t, "Select", nt.Name())
+	assert.Equal(t, "Select", nt.Clone(PostgresOption).Name())
+	assert.Equal(t, "Select", nt.MustAppend(` AND col_c = :c`).Name())
+	assert.Equal(t, "", MustCreateNamedTemplate(`SELECT 1`).Name())
+
+	_, err := nt.Args(map[string]any{})
+	require.Error(t, err)
+	assert.Equal(t, "template 'Select': named args missing: 'a', 'b'", err.Error())
+	var terr *TemplateError
+	require.True(t, errors.As(err, &terr))
+	assert.Equal(t, "Select", terr.Template)
+	var missing *MissingArgsError
+	require.True(t, errors.As(err, &missing))
+	assert.Equal(t, []string{"a", "b"}, missing.Names)
+	assert.True(t, errors.Is(err, ErrInvalidArgs))
+
+	_, err = nt.Interpolate(map[int]any{1: "a"})
+	assert.Equal(t, "template 'Select': invalid map - keys must be string", err.Error())
+	assert.True(t, errors.Is(err, ErrInvalidMap))
+	_, err = nt.Append(` AND col_c = :`)
+	assert.True(t, errors.As(err, &terr))
+	assert.True(t, errors.Is(err, ErrInvalidTemplate))
+
+	assert.PanicsWithError(t, "template 'Select': named arg 'b' missing", func() {
+		_ = nt.MustArgs(map[string]any{"a": 1})
+	})
+	assert.PanicsWithError(t, "template 'Select': named arg 'b' missing", func() {
+		_, _ = nt.MustStatementAndArgs(map[string]any{"a": 1})
+	})
+
+	_, err = NewNamedTemplate(`SELECT * FROM table WHERE col_a = :`, TemplateName("Bad"))
+	assert.Equal(t, `template 'Bad': named marker ':' without name (at line 1, column 35: "SELECT * FROM table WHERE col_a = :")`, err.Error())
+	var perr *ParseError
+	assert.True(t, errors.As(err, &perr))
+}
+
+func TestNamedTemplate_Name_RuntimeTokensAndBatches(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO {{table}} (col_a) VALUES (:a)...`, TemplateName("Insert"), AllowedValuesToken("table", "foo"))
+	_, err := nt.Batches([]map[string]any{{}}, TokenValues{"table": "foo"})
+	assert.Equal(t, "template 'Insert': row 0: named arg 'a' missing", err.Error())
+	_, err = nt.Args(TokenValues{"table": "bar"})
+	assert.Equal(t, "template 'Insert': runtime token 'table' value not allowed", err.Error())
+}
+
+func TestNamedTemplate_ConfigureReturnsCopy(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO table (col_a,col_b) VALUES (:a, :b)`)
+	nt2 := nt.OmissibleArgs("b")
+	assert.NotSame(t, nt, nt2)
+	assert.False(t, nt.GetArgsInfo()["b"].Omissible)
+	assert.True(t, nt2.GetArgsInfo()["b"].Omissible)
+	nt3 := nt2.DefaultValue("a", "default a").NullableStringArgs("b").RedactedArgs("b").ArgConverter("a", TrimConverter)
+	assert.False(t, nt2.GetArgsInfo()["a"].Omissible)
+	assert.False(t, nt2.GetArgsInfo()["b"].NullableString)
+	assert.Equal(t, 0, len(nt2.GetArgsInfo()["a"].Converters))
+	info := nt3.GetArgsInfo()
+	assert.True(t, info["a"].Omissible)
+	assert.True(t, info["b"].Omissible)
+	assert.True(t, info["b"].NullableString)
+	assert.True(t, info["b"].Redacted)
+	assert.Equal(t, 1, len(info["a"].Converters))
+	args, err := nt3.Args(map[string]any{"b": ""})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"default a", nil}, args)
+	_, err = nt.Args(map[string]any{"b": ""})
+	assert.Error(t, err)
+
+	// arg info is immutable...
+	info["a"].Positions[0] = 99
+	assert.Equal(t, []int{0}, nt3.GetArgsInfo()["a"].Positions)
+	info["a"].Converters[0] = LowerCaseConverter
+	args, err = nt3.Args(map[string]any{"a": " AA "})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"AA", nil}, args)
+}
+
+func TestNamedTemplate_ConcurrentUse(t *testing.T) {
+	set := MustCreateTemplateSet[struct {
+		Insert NamedTemplate `sql:"INSERT INTO {{table}} (col_a,col_b,col_c) VALUES (:a, :b, :c)"`
+		Select NamedTemplate `sql:"SELECT * FROM {{table}} WHERE id IN (:ids...)[[ AND status = :status]]"`
+	}](IdentifierToken("table"))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, err := set.Insert.Args(map[string]any{"a": i, "b": i, "c": i}, TokenValues{"table": "foo"})
+			assert.NoError(t, err)
+			_, err = set.Insert.Args(map[string]any{"a": i}, TokenValues{"table": "foo"})
+			assert.Error(t, err)
+			_, _, err = set.Select.StatementAndArgs(map[string]any{"ids": []int{i}}, TokenValues{"table": fmt.Sprintf("t%d", i%3)})
+			assert.NoError(t, err)
+			_, err = set.Select.Interpolate(map[string]any{"ids": []int{i}, "status": "a"}, TokenValues{"table": "foo"})
+			assert.NoError(t, err)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			nt := set.Insert.OmissibleArgs("b").DefaultValue("c", i).NullableStringArgs("a").ArgConverter("a", TrimConverter).RedactedArgs("a")
+			args, err := nt.Args(map[string]any{"a": ""}, TokenValues{"table": "foo"})
+			assert.NoError(t, err)
+			assert.Equal(t, []any{nil, nil, i}, args)
+			st := set.Select.NullableStringArgs("status").WithHooks(HookFuncs{})
+			s, args, err := st.StatementAndArgs(map[string]any{"ids": []int{i}, "status": ""}, TokenValues{"table": "foo"})
+			assert.NoError(t, err)
+			assert.Equal(t, `SELECT * FROM foo WHERE id IN (?) AND status = ?`, s)
+			assert.Equal(t, []any{i, nil}, args)
+		}(i)
+	}
+	wg.Wait()
+	for _, arg := range set.Insert.GetArgsInfo() {
+		assert.False(t, arg.Omissible)
+	}
+}
@@ -5,39 +5,109 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"sort"
+	"strings"
 )
 
 func (n *namedTemplate) copy() *namedTemplate {
-	r := newNamedTemplate(n.originalStatement, n.usePositionalTags, n.argTag, n.tokenOptions)
+	r := n.derive(n.originalStatement)
 	r.statement = n.statement
 	r.argsCount = n.argsCount
-	r.usePositionalTags = n.usePositionalTags
-	r.argTag = n.argTag
+	r.argNames = n.argNames
+	r.nameSpans = n.nameSpans
+	r.segments = n.segments
+	r.expanding = n.expanding
+	r.conditional = n.conditional
+	r.repeat = n.repeat
 	for name, arg := range n.args {
 		r.args[name] = arg.clone()
 	}
 	return r
 }
 
-func getOptions(options ...any) (Option, []TokenOption, error) {
-	opt := DefaultsOption
-	tokenOptions := make([]TokenOption, 0)
+// derive creates a new (un-built) template with the same options but a different statement
+func (n *namedTemplate) derive(statement string) *namedTemplate {
+	r := newNamedTemplate(statement, n.option, n.tokenOptions)
+	r.usePositionalTags = n.usePositionalTags
+	r.argTag = n.argTag
+	r.preserveCasts = n.preserveCasts
+	r.backslashEscapes = n.backslashEscapes
+	r.hashComments = n.hashComments
+	r.formatter = n.formatter
+	r.literals = n.literals
+	r.maxArgs = n.maxArgs
+	r.markers = n.markers
+	r.emptySlices = n.emptySlices
+	r.name = n.name
+	r.strictArgs = n.strictArgs
+	r.hooks = n.hooks
+	r.runtime = n.runtime.derive()
+	return r
+}
+
+// namedError wraps the error with the name of the template (if the template is named)
+func (n *namedTemplate) namedError(err error) error {
+	if err == nil || n.name == "" {
+		return err
+	}
+	return &TemplateError{Template: n.name, Err: err}
+}
+
+type templateOptions struct {
+	option        Option
+	tokenOptions  []TokenOption
+	markers       MarkerSyntax
+	emptySlices   EmptySliceBehaviour
+	unknownArgs   UnknownArgsBehaviour
+	runtimeTokens []RuntimeToken
+	hooks         []Hook
+	name          TemplateName
+}
+
+func getOptions(options ...any) (*templateOptions, error) {
+	result := &templateOptions{
+		option:       DefaultsOption,
+		tokenOptions: make([]TokenOption, 0),
+	}
 	for _, o := range options {
 		if o != nil {
-			o1, ok1 := o.(Option)
-			o2, ok2 := o.(TokenOption)
-			if !ok1 && !ok2 {
-				return nil, nil, errors.New("invalid option")
+			used := false
+			if o1, ok := o.(Option); ok {
+				result.option = o1
+				used = true
+			}
+			if o2, ok := o.(TokenOption); ok {
+				result.tokenOptions = append(result.tokenOptions, o2)
+				used = true
 			}
-			if ok1 {
-				opt = o1
+			if o4, ok := o.(RuntimeToken); ok {
+				result.runtimeTokens = append(result.runtimeTokens, o4)
+				used = true
 			}
-			if ok2 {
-				tokenOptions = append(tokenOptions, o2)
+			if o5, ok := o.(Hook); ok {
+				result.hooks = append(result.hooks, o5)
+				used = true
+			}
+			switch o3 := o.(type) {
+			case MarkerSyntax:
+				result.markers = o3
+				used = true
+			case EmptySliceBehaviour:
+				result.emptySlices = o3
+				used = true
+			case UnknownArgsBehaviour:
+				result.unknownArgs = o3
+				used = true
+			case TemplateName:
+				result.name = o3
+				used = true
+			}
+			if !used {
+				return nil, errors.New("invalid option")
 			}
 		}
 	}
-	return opt, tokenOptions, nil
+	return result, nil
 }
 
 func mappedArgs(args ...any) (map[string]any, error) {
@@ -53,15 +123,19 @@ func mappedArgs(args ...any) (map[string]any, error) {
 				result[targ.Name] = targ.Value
 			case sql.NamedArg:
 				result[targ.Name] = targ.Value
+			case TokenValues:
+				// runtime token values are not named args
 			default:
-				if vo := reflect.ValueOf(arg); vo.Kind() == reflect.Map {
+				if vo := reflect.ValueOf(arg); isMappableStruct(vo) {
+					mapStruct(vo, result)
+				} else if vo.Kind() == reflect.Map {
 					// it's a map, but not a map[string]any...
 					iter := vo.MapRange()
 					for iter.Next() {
 						if k, ok := iter.Key().Interface().(string); ok {
 							result[k] = iter.Value().Interface()
 						} else {
-							return nil, errors.New("invalid map - keys must be string")
+							return nil, ErrInvalidMap
 						}
 					}
 				} else {
@@ -84,3 +158,34 @@ func mappedArgs(args ...any) (map[string]any, error) {
 	}
 	return result, nil
 }
+
+// checkUnknownArgs returns an error (when the template is strict) if any of the supplied mapped args are not used by the template
+func (n *namedTemplate) checkUnknownArgs(mapped map[string]any) error {
+	if !n.strictArgs {
+		return nil
+	}
+	unknown := make([]string, 0)
+	for k := range mapped {
+		if !n.usesArg(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return &UnusedArgsError{Names: unknown}
+	}
+	return nil
+}
+
+// usesArg determines whether the supplied arg name is used by the template (either as a named arg or as the root of a dotted named arg)
+func (n *namedTemplate) usesArg(name string) bool {
+	if _, ok := n.args[name]; ok {
+		return true
+	}
+	for argName := range n.args {
+		if strings.HasPrefix(argName, name+".") {
+			return true
+		}
+	}
+	return false
+}
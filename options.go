@@ -6,6 +6,29 @@ var DefaultUsePositionalTags = false
 // DefaultArgTag is the default setting for arg tag placeholders
 var DefaultArgTag = "?"
 
+// DefaultPreserveCasts is the default setting for whether `::` in statements are type casts (rather than an escaped ':')
+var DefaultPreserveCasts = false
+
+// DefaultBackslashEscapes is the default setting for whether backslash escapes are recognised in quoted strings
+// (e.g. `'it\'s'`) when parsing statements
+var DefaultBackslashEscapes = false
+
+// DefaultHashComments is the default setting for whether `#` starts a comment (to end of line) when parsing statements
+var DefaultHashComments = false
+
+// DefaultStrictArgs is the default setting for whether supplied args that are not used by the template cause an error
+// (see UnknownArgsBehaviour)
+var DefaultStrictArgs = false
+
+// DefaultMaxRuntimeVariants is the default max number of variants (i.e. distinct sets of runtime token values) cached
+// per template with runtime tokens - the least recently used variants are evicted when exceeded (0 = no caching)
+//
+// Set this before creating templates (the max is determined when the template is created)
+var DefaultMaxRuntimeVariants = 256
+
+// DefaultMaxArgs is the default max number of args per statement used when chunking batches (0 = no limit)
+var DefaultMaxArgs = 0
+
 // Option is the interface that can be passed to NewNamedTemplate or MustCreateNamedTemplate
 // and determines whether positional tags (i.e. numbered tags) can be used and the arg placeholder to be used
 type Option interface {
@@ -18,6 +41,61 @@ type Option interface {
 	ArgTag() string
 }
 
+// CastOption is an optional interface that an Option can also implement to specify whether `::` in
+// the statement is a type cast (e.g. Postgres `created_at::date`) rather than an escaped ':'
+//
+// When casts are preserved, `::` is passed through untouched - so `:id::uuid` yields the named arg "id" followed
+// by a `::uuid` cast, and a literal ':' can be specified by escaping it as `\:`
+//
+// If an Option does not implement CastOption then `::` is treated as an escaped ':'
+type CastOption interface {
+	// PreserveCasts specifies whether `::` in the statement is a type cast
+	PreserveCasts() bool
+}
+
+// LexicalOption is an optional interface that an Option can also implement to specify dialect specific syntax of
+// string literals and comments - so that named markers within them are correctly ignored
+//
+// e.g. MySql recognises backslash escapes in quoted strings (`'it\'s'`) and `#` comments (`# see: docs`)
+//
+// If an Option does not implement LexicalOption then neither are recognised (backslash escapes are still recognised
+// in Postgres `E'...'` strings)
+type LexicalOption interface {
+	// BackslashEscapes specifies whether backslash escapes are recognised in quoted strings
+	BackslashEscapes() bool
+	// HashComments specifies whether `#` starts a comment (to end of line)
+	HashComments() bool
+}
+
+// ArgTagFormatter is an optional interface that an Option can also implement to format the arg placeholders
+// used in the final sql statement (rather than them being Option.ArgTag or Option.ArgTag + position)
+//
+// Option.UsePositionalTags still determines whether repeated named args re-use the same placeholder (and position)
+type ArgTagFormatter interface {
+	// FormatArgTag returns the arg placeholder for the given position (1 based) and named arg
+	FormatArgTag(position int, name string) string
+}
+
+// MaxArgsOption is an optional interface that an Option can also implement to specify the max number of args
+// per statement - used by NamedTemplate.Batches to chunk rows into multiple statements
+//
+// If an Option does not implement MaxArgsOption (or returns 0) then batches are not chunked
+type MaxArgsOption interface {
+	// MaxArgs specifies the max number of args per statement (0 = no limit)
+	MaxArgs() int
+}
+
+// LiteralFormatter is an optional interface that an Option can also implement to format arg values as sql literals
+// (as used by NamedTemplate.Interpolate)
+//
+// If an Option does not implement LiteralFormatter then values are formatted as ANSI sql literals
+type LiteralFormatter interface {
+	// FormatLiteral returns the sql literal for the given value
+	//
+	// The value is never nil, a pointer or a driver.Valuer (these are resolved before FormatLiteral is called)
+	FormatLiteral(v any) string
+}
+
 // TokenOption is an interface that can be provided to NewNamedTemplate or MustCreateNamedTemplate
 // to replace tokens in the statement (tokens are denoted by `{{token}}`)
 //
@@ -35,20 +113,112 @@ func (m TokenOptionMap) Replace(token string) (string, bool) {
 	return s, ok
 }
 
+// MarkerSyntax is an option that can be passed to NewNamedTemplate or MustCreateNamedTemplate
+// to specify the syntax of named arg markers in the statement
+//
+// If no MarkerSyntax option is provided, ColonMarkers is used
+type MarkerSyntax int
+
+const (
+	ColonMarkers       MarkerSyntax = iota // named arg markers like :name (escaped as ::)
+	AtMarkers                              // named arg markers like @name (escaped as \@) - e.g. SQL Server style - NB. T-SQL globals (e.g. @@ROWCOUNT) are passed through untouched
+	DollarMarkers                          // named arg markers like $name (escaped as $$) - NB. Postgres dollar-quoted strings are not recognised with this syntax
+	ColonBraceMarkers                      // named arg markers like :{name} (escaped as ::{) - names may contain any character except '}'
+	DollarBraceMarkers                     // named arg markers like ${name} (escaped as $${) - names may contain any character except '}'
+)
+
+func (m MarkerSyntax) prefix() rune {
+	switch m {
+	case AtMarkers:
+		return '@'
+	case DollarMarkers, DollarBraceMarkers:
+		return '$'
+	}
+	return ':'
+}
+
+func (m MarkerSyntax) braced() bool {
+	return m == ColonBraceMarkers || m == DollarBraceMarkers
+}
+
+// TemplateName is an option that can be passed to NewNamedTemplate or MustCreateNamedTemplate to name the template
+// (see NamedTemplate.Name)
+//
+// Templates created by NewTemplateSet are named by their field path (e.g. "Users.Select") - any TemplateName option is ignored
+type TemplateName string
+
+// EmptySliceBehaviour is an option that can be passed to NewNamedTemplate or MustCreateNamedTemplate
+// to specify how expanded named args (e.g. `WHERE id IN (:ids...)`) are treated when the supplied slice is empty
+//
+// If no EmptySliceBehaviour option is provided, EmptySliceNull is used
+type EmptySliceBehaviour int
+
+const (
+	EmptySliceNull  EmptySliceBehaviour = iota // empty slices are expanded to a single nil arg - e.g. `IN (NULL)` (which matches nothing)
+	EmptySliceError                            // empty slices cause an error
+)
+
+// UnknownArgsBehaviour is an option that can be passed to NewNamedTemplate, MustCreateNamedTemplate or NewTemplateSet
+// to specify how supplied args (e.g. map keys or struct fields) that are not used by the template are treated
+//
+// If no UnknownArgsBehaviour option is provided, UnknownArgsDefault is used
+type UnknownArgsBehaviour int
+
+const (
+	UnknownArgsDefault UnknownArgsBehaviour = iota // determined by DefaultStrictArgs (when the template is created)
+	UnknownArgsIgnore                              // unknown supplied args are ignored
+	UnknownArgsError                               // unknown supplied args cause an error
+)
+
+func (b UnknownArgsBehaviour) strict() bool {
+	if b == UnknownArgsDefault {
+		return DefaultStrictArgs
+	}
+	return b == UnknownArgsError
+}
+
 var (
-	MySqlOption    Option = _MySqlOption    // option to produce final args like ?, ?, ? (e.g. for https://github.com/go-sql-driver/mysql)
-	PostgresOption Option = _PostgresOption // option to produce final args like $1, $2, $3 (e.g. for https://github.com/lib/pq or https://github.com/jackc/pgx)
-	DefaultsOption Option = _DefaultsOption // option to produce final args determined by DefaultUsePositionalTags and DefaultArgTag
+	MySqlOption     Option = _MySqlOption     // option to produce final args like ?, ?, ? (e.g. for https://github.com/go-sql-driver/mysql) - and recognises backslash escapes and `#` comments
+	PostgresOption  Option = _PostgresOption  // option to produce final args like $1, $2, $3 (e.g. for https://github.com/lib/pq or https://github.com/jackc/pgx) - and preserves `::` type casts
+	SqlServerOption Option = _SqlServerOption // option to produce final args like @p1, @p2, @p3 (e.g. for https://github.com/microsoft/go-mssqldb)
+	OracleOption    Option = _OracleOption    // option to produce final args like :1, :2, :3 (e.g. for https://github.com/sijms/go-ora)
+	SqliteOption    Option = _SqliteOption    // option to produce final args like ?1, ?2, ?3 (e.g. for https://github.com/mattn/go-sqlite3)
+	DefaultsOption  Option = _DefaultsOption  // option to produce final args determined by DefaultUsePositionalTags and DefaultArgTag
 )
 
 var (
 	_MySqlOption = &option{
 		usePositionalTags: false,
 		argTag:            "?",
+		backslashEscapes:  true,
+		hashComments:      true,
+		maxArgs:           65535,
+		literals:          mySqlLiterals,
 	}
 	_PostgresOption = &option{
 		usePositionalTags: true,
 		argTag:            "$",
+		preserveCasts:     true,
+		maxArgs:           65535,
+		literals:          postgresLiterals,
+	}
+	_SqlServerOption = &option{
+		usePositionalTags: true,
+		argTag:            "@p",
+		maxArgs:           2098, // 2100 less the 2 params added by sp_executesql
+		literals:          sqlServerLiterals,
+	}
+	_OracleOption = &option{
+		usePositionalTags: true,
+		argTag:            ":",
+		maxArgs:           65535,
+		literals:          oracleLiterals,
+	}
+	_SqliteOption = &option{
+		usePositionalTags: true,
+		argTag:            "?",
+		maxArgs:           32766,
+		literals:          sqliteLiterals,
 	}
 	_DefaultsOption = &defaultOption{}
 )
@@ -56,6 +226,11 @@ var (
 type option struct {
 	usePositionalTags bool
 	argTag            string
+	preserveCasts     bool
+	backslashEscapes  bool
+	hashComments      bool
+	maxArgs           int
+	literals          *literalDialect
 }
 
 func (d *option) UsePositionalTags() bool {
@@ -66,6 +241,29 @@ func (d *option) ArgTag() string {
 	return d.argTag
 }
 
+func (d *option) PreserveCasts() bool {
+	return d.preserveCasts
+}
+
+func (d *option) BackslashEscapes() bool {
+	return d.backslashEscapes
+}
+
+func (d *option) HashComments() bool {
+	return d.hashComments
+}
+
+func (d *option) MaxArgs() int {
+	return d.maxArgs
+}
+
+func (d *option) FormatLiteral(v any) string {
+	if d.literals == nil {
+		return ansiLiterals.FormatLiteral(v)
+	}
+	return d.literals.FormatLiteral(v)
+}
+
 type defaultOption struct {
 }
 
@@ -76,3 +274,65 @@ func (d *defaultOption) UsePositionalTags() bool {
 func (d *defaultOption) ArgTag() string {
 	return DefaultArgTag
 }
+
+func (d *defaultOption) PreserveCasts() bool {
+	return DefaultPreserveCasts
+}
+
+func (d *defaultOption) BackslashEscapes() bool {
+	return DefaultBackslashEscapes
+}
+
+func (d *defaultOption) HashComments() bool {
+	return DefaultHashComments
+}
+
+func (d *defaultOption) MaxArgs() int {
+	return DefaultMaxArgs
+}
+
+func (d *defaultOption) FormatLiteral(v any) string {
+	return ansiLiterals.FormatLiteral(v)
+}
+
+func preservesCasts(opt Option) bool {
+	if co, ok := opt.(CastOption); ok {
+		return co.PreserveCasts()
+	}
+	return false
+}
+
+func backslashEscapes(opt Option) bool {
+	if lo, ok := opt.(LexicalOption); ok {
+		return lo.BackslashEscapes()
+	}
+	return false
+}
+
+func hashComments(opt Option) bool {
+	if lo, ok := opt.(LexicalOption); ok {
+		return lo.HashComments()
+	}
+	return false
+}
+
+func argTagFormatter(opt Option) ArgTagFormatter {
+	if f, ok := opt.(ArgTagFormatter); ok {
+		return f
+	}
+	return nil
+}
+
+func maxArgs(opt Option) int {
+	if mo, ok := opt.(MaxArgsOption); ok {
+		return mo.MaxArgs()
+	}
+	return 0
+}
+
+func literalFormatter(opt Option) LiteralFormatter {
+	if f, ok := opt.(LiteralFormatter); ok {
+		return f
+	}
+	return ansiLiterals
+}
@@ -9,16 +9,131 @@ func TestDefaults(t *testing.T) {
 	assert.False(t, DefaultUsePositionalTags)
 	assert.Equal(t, "?", DefaultArgTag)
 
+	assert.False(t, DefaultPreserveCasts)
+	assert.False(t, DefaultBackslashEscapes)
+	assert.False(t, DefaultHashComments)
+	assert.Equal(t, 0, DefaultMaxArgs)
+	assert.Equal(t, 256, DefaultMaxRuntimeVariants)
+	assert.False(t, DefaultStrictArgs)
+
 	assert.False(t, DefaultsOption.UsePositionalTags())
 	assert.Equal(t, "?", DefaultsOption.ArgTag())
+	assert.False(t, preservesCasts(DefaultsOption))
+	assert.False(t, backslashEscapes(DefaultsOption))
+	assert.False(t, hashComments(DefaultsOption))
+	assert.Equal(t, 0, maxArgs(DefaultsOption))
 }
 
 func TestMySqlOption(t *testing.T) {
 	assert.False(t, MySqlOption.UsePositionalTags())
 	assert.Equal(t, "?", MySqlOption.ArgTag())
+	assert.False(t, preservesCasts(MySqlOption))
+	assert.True(t, backslashEscapes(MySqlOption))
+	assert.True(t, hashComments(MySqlOption))
+	assert.Equal(t, 65535, maxArgs(MySqlOption))
 }
 
 func TestPostgresOption(t *testing.T) {
 	assert.True(t, PostgresOption.UsePositionalTags())
 	assert.Equal(t, "$", PostgresOption.ArgTag())
+	assert.True(t, preservesCasts(PostgresOption))
+	assert.False(t, backslashEscapes(PostgresOption))
+	assert.False(t, hashComments(PostgresOption))
+	assert.Equal(t, 65535, maxArgs(PostgresOption))
+}
+
+func TestSqlServerOption(t *testing.T) {
+	assert.True(t, SqlServerOption.UsePositionalTags())
+	assert.Equal(t, "@p", SqlServerOption.ArgTag())
+	assert.False(t, preservesCasts(SqlServerOption))
+	assert.Equal(t, 2098, maxArgs(SqlServerOption))
+}
+
+func TestOracleOption(t *testing.T) {
+	assert.True(t, OracleOption.UsePositionalTags())
+	assert.Equal(t, ":", OracleOption.ArgTag())
+	assert.False(t, preservesCasts(OracleOption))
+	assert.Equal(t, 65535, maxArgs(OracleOption))
+}
+
+func TestSqliteOption(t *testing.T) {
+	assert.True(t, SqliteOption.UsePositionalTags())
+	assert.Equal(t, "?", SqliteOption.ArgTag())
+	assert.False(t, preservesCasts(SqliteOption))
+	assert.Equal(t, 32766, maxArgs(SqliteOption))
+}
+
+func TestArgTagFormatter(t *testing.T) {
+	assert.Nil(t, argTagFormatter(DefaultsOption))
+	assert.Nil(t, argTagFormatter(PostgresOption))
+	f := argTagFormatter(&testFormatterOption{})
+	assert.NotNil(t, f)
+	assert.Equal(t, ":a", f.FormatArgTag(1, "a"))
+}
+
+type testFormatterOption struct {
+	positional bool
+}
+
+func (o *testFormatterOption) UsePositionalTags() bool {
+	return o.positional
+}
+
+func (o *testFormatterOption) ArgTag() string {
+	return ":"
+}
+
+func (o *testFormatterOption) FormatArgTag(position int, name string) string {
+	return ":" + name
+}
+
+func TestPreservesCasts_NotCastOption(t *testing.T) {
+	assert.False(t, preservesCasts(&testOption{}))
+}
+
+func TestLexical_NotLexicalOption(t *testing.T) {
+	assert.False(t, backslashEscapes(&testOption{}))
+	assert.False(t, hashComments(&testOption{}))
+}
+
+func TestMaxArgs_NotMaxArgsOption(t *testing.T) {
+	assert.Equal(t, 0, maxArgs(&testOption{}))
+}
+
+type testOption struct{}
+
+func (o *testOption) UsePositionalTags() bool {
+	return false
+}
+
+func (o *testOption) ArgTag() string {
+	return "?"
+}
+
+type testMaxArgsOption struct {
+	max int
+}
+
+func (o *testMaxArgsOption) UsePositionalTags() bool {
+	return true
+}
+
+func (o *testMaxArgsOption) ArgTag() string {
+	return "$"
+}
+
+func (o *testMaxArgsOption) MaxArgs() int {
+	return o.max
+}
+
+func TestUnknownArgsBehaviour(t *testing.T) {
+	assert.False(t, UnknownArgsDefault.strict())
+	assert.False(t, UnknownArgsIgnore.strict())
+	assert.True(t, UnknownArgsError.strict())
+	defer func() {
+		DefaultStrictArgs = false
+	}()
+	DefaultStrictArgs = true
+	assert.True(t, UnknownArgsDefault.strict())
+	assert.False(t, UnknownArgsIgnore.strict())
 }
@@ -0,0 +1,198 @@
+package sqlnt
+
+import (
+	"container/list"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RuntimeToken is an option that can be passed to NewNamedTemplate or MustCreateNamedTemplate to denote a token
+// (e.g. `{{sort}}`) that is not replaced when the template is created - but is instead replaced at call time
+// (e.g. StatementAndArgs, Exec, Query etc.) from the TokenValues supplied in the args
+//
+// Use AllowedValuesToken or IdentifierToken to create a RuntimeToken (or provide your own implementation)
+type RuntimeToken interface {
+	// Token returns the token name (as used in the statement - e.g. "sort" for `{{sort}}`)
+	Token() string
+	// Allowed determines whether the supplied replacement value is allowed (i.e. is safe to be used in the statement)
+	Allowed(value string) bool
+}
+
+// TokenValues is the replacement values for runtime tokens (see RuntimeToken)
+//
+// TokenValues can be passed as one of the args to StatementAndArgs, Args, Exec, Query etc. - example:
+//
+//	tmp := sqlnt.MustCreateNamedTemplate(`SELECT * FROM table ORDER BY {{sort}}`, sqlnt.AllowedValuesToken("sort", "name", "created_at DESC"))
+//	rows, err := tmp.Query(db, sqlnt.TokenValues{"sort": "name"})
+type TokenValues map[string]string
+
+// AllowedValuesToken creates a RuntimeToken where the replacement value must be one of the specified values
+func AllowedValuesToken(token string, values ...string) RuntimeToken {
+	allowed := make(map[string]bool, len(values))
+	for _, v := range values {
+		allowed[v] = true
+	}
+	return &allowedValuesToken{
+		token:   token,
+		allowed: allowed,
+	}
+}
+
+// IdentifierToken creates a RuntimeToken where the replacement value must be an unquoted sql identifier
+// (optionally qualified - e.g. `table_name` or `schema_name.table_name`)
+func IdentifierToken(token string) RuntimeToken {
+	return &identifierToken{
+		token: token,
+	}
+}
+
+type allowedValuesToken struct {
+	token   string
+	allowed map[string]bool
+}
+
+func (t *allowedValuesToken) Token() string {
+	return t.token
+}
+
+func (t *allowedValuesToken) Allowed(value string) bool {
+	return t.allowed[value]
+}
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+type identifierToken struct {
+	token string
+}
+
+func (t *identifierToken) Token() string {
+	return t.token
+}
+
+func (t *identifierToken) Allowed(value string) bool {
+	return identifierRegexp.MatchString(value)
+}
+
+// runtimeTokens is the runtime tokens of a template - and the cache of templates for each resolved variant
+//
+// The cache is bounded (see DefaultMaxRuntimeVariants) - with the least recently used variants evicted
+type runtimeTokens struct {
+	tokens   []RuntimeToken
+	max      int
+	mutex    sync.Mutex
+	variants map[string]*list.Element
+	lru      *list.List
+}
+
+// runtimeVariant is a cached variant template (as the value of runtimeTokens.lru elements)
+type runtimeVariant struct {
+	key      string
+	template *namedTemplate
+}
+
+func newRuntimeTokens(tokens []RuntimeToken) *runtimeTokens {
+	if len(tokens) == 0 {
+		return nil
+	}
+	return &runtimeTokens{
+		tokens:   tokens,
+		max:      DefaultMaxRuntimeVariants,
+		variants: map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// get returns the cached variant for the key (marking it as most recently used)
+func (rt *runtimeTokens) get(key string) (*namedTemplate, bool) {
+	if e, ok := rt.variants[key]; ok {
+		rt.lru.MoveToFront(e)
+		return e.Value.(*runtimeVariant).template, true
+	}
+	return nil, false
+}
+
+// put caches the variant for the key - evicting the least recently used variants when the cache is full
+func (rt *runtimeTokens) put(key string, r *namedTemplate) {
+	if rt.max <= 0 {
+		return
+	}
+	for rt.lru.Len() >= rt.max {
+		oldest := rt.lru.Back()
+		rt.lru.Remove(oldest)
+		delete(rt.variants, oldest.Value.(*runtimeVariant).key)
+	}
+	rt.variants[key] = rt.lru.PushFront(&runtimeVariant{key: key, template: r})
+}
+
+// derive returns the runtime tokens for a derived template (with an empty cache of variants)
+func (rt *runtimeTokens) derive() *runtimeTokens {
+	if rt == nil {
+		return nil
+	}
+	r := newRuntimeTokens(rt.tokens)
+	r.max = rt.max
+	return r
+}
+
+func (rt *runtimeTokens) has(token string) bool {
+	if rt != nil {
+		for _, t := range rt.tokens {
+			if t.Token() == token {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// variant returns the template (built with the runtime tokens replaced) for the TokenValues supplied in the args
+func (n *namedTemplate) variant(args ...any) (*namedTemplate, error) {
+	rt := n.runtime
+	values := map[string]string{}
+	for _, arg := range args {
+		if tv, ok := arg.(TokenValues); ok {
+			for k, v := range tv {
+				values[k] = v
+			}
+		}
+	}
+	keys := make([]string, 0, len(rt.tokens))
+	replacements := make(map[string]string, len(rt.tokens))
+	for _, t := range rt.tokens {
+		token := t.Token()
+		if v, ok := values[token]; !ok {
+			return nil, newArgsError("runtime token '%s' not supplied", token)
+		} else if !t.Allowed(v) {
+			return nil, newArgsError("runtime token '%s' value not allowed", token)
+		} else if _, ok = replacements[token]; !ok {
+			replacements[token] = v
+			keys = append(keys, token+"="+v)
+		}
+	}
+	sort.Strings(keys)
+	key := strings.Join(keys, "\x00")
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+	if r, ok := rt.get(key); ok {
+		return r, nil
+	}
+	r := n.derive(tokenRegexp.ReplaceAllStringFunc(n.originalStatement, func(s string) string {
+		if v, ok := replacements[s[2:len(s)-2]]; ok {
+			return v
+		}
+		return s
+	}))
+	r.runtime = nil
+	if err := r.buildArgs(); err != nil {
+		return nil, err
+	}
+	for name, arg := range n.args {
+		if rarg, ok := r.args[name]; ok {
+			arg.copyOptionsTo(rarg)
+		}
+	}
+	rt.put(key, r)
+	return r, nil
+}
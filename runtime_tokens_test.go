@@ -0,0 +1,226 @@
+package sqlnt
+
+import (
+	"context"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestRuntimeTokens(t *testing.T) {
+	testCases := []struct {
+		statement       string
+		options         []any
+		inArgs          []any
+		expectStatement string
+		expectOutArgs   []any
+		expectError     string
+	}{
+		{
+			statement:       `SELECT * FROM {{table}} WHERE col_a = :a ORDER BY {{sort}}`,
+			options:         []any{IdentifierToken("table"), AllowedValuesToken("sort", "col_a", "col_b DESC")},
+			inArgs:          []any{TokenValues{"table": "tenant_1.foo", "sort": "col_b DESC"}, map[string]any{"a": "aa"}},
+			expectStatement: `SELECT * FROM tenant_1.foo WHERE col_a = ? ORDER BY col_b DESC`,
+			expectOutArgs:   []any{"aa"},
+		},
+		{
+			statement:       `SELECT * FROM {{table}} WHERE col_a = :a AND col_b = :b ORDER BY {{sort}}`,
+			options:         []any{PostgresOption, IdentifierToken("table"), AllowedValuesToken("sort", "col_a", "col_b DESC")},
+			inArgs:          []any{map[string]any{"a": "aa"}, TokenValues{"table": "bar"}, TokenValues{"sort": "col_a"}, map[string]any{"b": "bb"}},
+			expectStatement: `SELECT * FROM bar WHERE col_a = $1 AND col_b = $2 ORDER BY col_a`,
+			expectOutArgs:   []any{"aa", "bb"},
+		},
+		{
+			statement:       `SELECT * FROM {{tableName}} ORDER BY {{sort}}`,
+			options:         []any{testTokenOption, AllowedValuesToken("sort", "col_a")},
+			inArgs:          []any{TokenValues{"sort": "col_a", "other": "ignored"}},
+			expectStatement: `SELECT * FROM foo ORDER BY col_a`,
+			expectOutArgs:   []any{},
+		},
+		{
+			statement:       `SELECT * FROM {{tableName}} ORDER BY {{tableName}}`,
+			options:         []any{testTokenOption, IdentifierToken("tableName")},
+			inArgs:          []any{TokenValues{"tableName": "bar"}},
+			expectStatement: `SELECT * FROM bar ORDER BY bar`,
+			expectOutArgs:   []any{},
+		},
+		{
+			statement:   `SELECT * FROM {{table}}`,
+			options:     []any{IdentifierToken("table")},
+			inArgs:      []any{TokenValues{"table": "foo; DROP TABLE foo"}},
+			expectError: "runtime token 'table' value not allowed",
+		},
+		{
+			statement:   `SELECT * FROM foo ORDER BY {{sort}}`,
+			options:     []any{AllowedValuesToken("sort", "col_a")},
+			inArgs:      []any{TokenValues{"sort": "col_b"}},
+			expectError: "runtime token 'sort' value not allowed",
+		},
+		{
+			statement:   `SELECT * FROM {{table}}`,
+			options:     []any{IdentifierToken("table")},
+			inArgs:      []any{map[string]any{"table": "foo"}},
+			expectError: "runtime token 'table' not supplied",
+		},
+		{
+			statement:   `SELECT * FROM foo ORDER BY {{sort}}`,
+			options:     []any{AllowedValuesToken("sort", "col_a", "col_a = :a")},
+			inArgs:      []any{TokenValues{"sort": "col_a = :a"}},
+			expectError: "named arg 'a' missing",
+		},
+	}
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("[%d]%s", i+1, tc.statement), func(t *testing.T) {
+			nt, err := NewNamedTemplate(tc.statement, tc.options...)
+			require.NoError(t, err)
+			stmt, args, err := nt.StatementAndArgs(tc.inArgs...)
+			if tc.expectError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectError, err.Error())
+				_, err = nt.Args(tc.inArgs...)
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectStatement, stmt)
+				assert.Equal(t, tc.expectOutArgs, args)
+				args, err = nt.Args(tc.inArgs...)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectOutArgs, args)
+			}
+		})
+	}
+}
+
+func TestRuntimeTokens_Statement(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM {{table}} WHERE col_a = :a`, IdentifierToken("table"))
+	assert.Equal(t, `SELECT * FROM {{table}} WHERE col_a = ?`, nt.Statement())
+
+	_, err := NewNamedTemplate(`SELECT * FROM {{table}} WHERE col_a = :a`, IdentifierToken("other"))
+	assert.Error(t, err)
+	assert.Equal(t, "unknown token: table (at line 1, column 15)", err.Error())
+}
+
+func TestRuntimeTokens_VariantsCached(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM {{table}} WHERE col_a = :a`, IdentifierToken("table")).(*namedTemplate)
+	v1, err := nt.variant(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	v2, err := nt.variant(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	assert.Same(t, v1, v2)
+	v3, err := nt.variant(TokenValues{"table": "bar"})
+	require.NoError(t, err)
+	assert.NotSame(t, v1, v3)
+	assert.Equal(t, 2, len(nt.runtime.variants))
+
+	// changing arg options returns a copy with its own cache of variants...
+	nt2 := nt.DefaultValue("a", "default a").(*namedTemplate)
+	assert.Equal(t, 0, len(nt2.runtime.variants))
+	assert.Equal(t, 2, len(nt.runtime.variants))
+	_, args, err := nt2.StatementAndArgs(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, []any{"default a"}, args)
+	_, err = nt.Args(TokenValues{"table": "foo"})
+	assert.Error(t, err)
+	nt = nt2
+
+	c := nt.Clone(PostgresOption)
+	stmt, args, err := c.StatementAndArgs(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM foo WHERE col_a = $1`, stmt)
+	assert.Equal(t, []any{"default a"}, args)
+	assert.Equal(t, 1, len(nt.runtime.variants))
+}
+
+func TestRuntimeTokens_VariantsBounded(t *testing.T) {
+	defer func(max int) {
+		DefaultMaxRuntimeVariants = max
+	}(DefaultMaxRuntimeVariants)
+	DefaultMaxRuntimeVariants = 2
+	nt := MustCreateNamedTemplate(`SELECT * FROM {{table}} WHERE col_a = :a`, IdentifierToken("table")).(*namedTemplate)
+	v1, err := nt.variant(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	_, err = nt.variant(TokenValues{"table": "bar"})
+	require.NoError(t, err)
+	v, err := nt.variant(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	assert.Same(t, v1, v)
+	// least recently used ("bar") is evicted...
+	_, err = nt.variant(TokenValues{"table": "baz"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(nt.runtime.variants))
+	assert.Equal(t, 2, nt.runtime.lru.Len())
+	_, ok := nt.runtime.variants["table=bar"]
+	assert.False(t, ok)
+	v, err = nt.variant(TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	assert.Same(t, v1, v)
+	for i := 0; i < 100; i++ {
+		_, err = nt.variant(TokenValues{"table": fmt.Sprintf("tenant_%d", i)})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, len(nt.runtime.variants))
+	// derived templates retain the max...
+	DefaultMaxRuntimeVariants = 0
+	nt2 := nt.DefaultValue("a", "default a").(*namedTemplate)
+	assert.Equal(t, 2, nt2.runtime.max)
+
+	nt3 := MustCreateNamedTemplate(`SELECT * FROM {{table}} WHERE col_a = :a`, IdentifierToken("table")).(*namedTemplate)
+	stmt, _, err := nt3.StatementAndArgs(TokenValues{"table": "foo"}, map[string]any{"a": "aa"})
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT * FROM foo WHERE col_a = ?`, stmt)
+	assert.Equal(t, 0, len(nt3.runtime.variants))
+}
+
+func TestRuntimeTokens_Exec(t *testing.T) {
+	nt := MustCreateNamedTemplate(`DELETE FROM {{table}} WHERE col_a = :a`, IdentifierToken("table"))
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectExec(`DELETE FROM foo WHERE col_a = ?`).
+		WithArgs("aa").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = nt.Exec(db, TokenValues{"table": "foo"}, map[string]any{"a": "aa"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = nt.Prepare(context.Background(), db)
+	assert.Error(t, err)
+	assert.Equal(t, "cannot prepare template with runtime tokens", err.Error())
+}
+
+func TestRuntimeTokens_Batches(t *testing.T) {
+	nt := MustCreateNamedTemplate(`INSERT INTO {{table}} (col_a) VALUES (:a)...`, IdentifierToken("table"))
+	batches, err := nt.Batches([]map[string]any{{"a": 1}, {"a": 2}}, TokenValues{"table": "foo"})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(batches))
+	assert.Equal(t, `INSERT INTO foo (col_a) VALUES (?), (?)`, batches[0].Statement)
+
+	_, err = nt.Batches([]map[string]any{{"a": 1}})
+	assert.Error(t, err)
+	assert.Equal(t, "runtime token 'table' not supplied", err.Error())
+}
+
+func TestIdentifierToken(t *testing.T) {
+	tk := IdentifierToken("table")
+	assert.Equal(t, "table", tk.Token())
+	assert.True(t, tk.Allowed("foo"))
+	assert.True(t, tk.Allowed("_foo_1"))
+	assert.True(t, tk.Allowed("schema.foo"))
+	assert.False(t, tk.Allowed(""))
+	assert.False(t, tk.Allowed("1foo"))
+	assert.False(t, tk.Allowed("foo bar"))
+	assert.False(t, tk.Allowed("foo."))
+	assert.False(t, tk.Allowed("foo;--"))
+	assert.False(t, tk.Allowed(`"foo"`))
+}
+
+func TestAllowedValuesToken(t *testing.T) {
+	tk := AllowedValuesToken("sort", "col_a", "col_b DESC")
+	assert.Equal(t, "sort", tk.Token())
+	assert.True(t, tk.Allowed("col_a"))
+	assert.True(t, tk.Allowed("col_b DESC"))
+	assert.False(t, tk.Allowed("col_b"))
+	assert.False(t, tk.Allowed(""))
+}
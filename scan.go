@@ -0,0 +1,196 @@
+package sqlnt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// DefaultStrictScan is the default setting for whether QueryInto and QueryOne return an error when
+// result columns cannot be mapped to struct fields
+var DefaultStrictScan = false
+
+// QueryInto performs a query of the supplied template on the supplied db (e.g. *sql.DB, *sql.Tx or *sql.Conn) with
+// the supplied named args - and scans each resulting row into a T
+//
+// If T is a struct (or pointer to struct), result columns are mapped to fields by `db` tag, `json` tag or field name - matched
+// case-insensitively and ignoring underscores (so column "created_at" maps to field CreatedAt). Result columns that cannot
+// be mapped are ignored (unless DefaultStrictScan is set - in which case an error is returned)
+//
+// If T is not a struct (or is a struct that implements sql.Scanner or is time.Time), the query must return
+// a single column
+//
+// NULLs can be scanned into pointer fields or sql.Null* fields (e.g. sql.NullString)
+func QueryInto[T any](ctx context.Context, template NamedTemplate, db Querier, args ...any) ([]T, error) {
+	rows, err := template.QueryContext(ctx, db, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ScanRows[T](rows, DefaultStrictScan)
+}
+
+// QueryOne is the same as QueryInto, except only the first row is scanned
+//
+// Returns sql.ErrNoRows if the query returns no rows
+func QueryOne[T any](ctx context.Context, template NamedTemplate, db Querier, args ...any) (T, error) {
+	var result T
+	rows, err := template.QueryContext(ctx, db, args...)
+	if err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	scanner, err := newRowScanner[T](rows, DefaultStrictScan)
+	if err != nil {
+		return result, err
+	}
+	if !rows.Next() {
+		if err = rows.Err(); err == nil {
+			err = sql.ErrNoRows
+		}
+		return result, err
+	}
+	if result, err = scanner.scan(rows); err == nil {
+		err = rows.Close()
+	}
+	return result, err
+}
+
+// ScanRows scans each of the supplied rows into a T (and closes the rows)
+//
+// See QueryInto for how result columns are mapped to T - if strict is true, an error is returned for any
+// result columns that cannot be mapped to struct fields
+func ScanRows[T any](rows *sql.Rows, strict bool) ([]T, error) {
+	defer func() {
+		_ = rows.Close()
+	}()
+	scanner, err := newRowScanner[T](rows, strict)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]T, 0)
+	for rows.Next() {
+		item, err := scanner.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, rows.Err()
+}
+
+type rowScanner[T any] struct {
+	isPtr   bool
+	scalar  bool
+	columns [][]int
+}
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+func newRowScanner[T any](rows *sql.Rows, strict bool) (*rowScanner[T], error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &rowScanner[T]{}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct {
+		result.isPtr = true
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || reflect.PointerTo(t).Implements(scannerType) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("cannot scan %d columns into %s", len(columns), t.String())
+		}
+		result.isPtr = false
+		result.scalar = true
+		return result, nil
+	}
+	fields := getStructMapping(t).fields
+	unmapped := make([]string, 0)
+	result.columns = make([][]int, len(columns))
+	for i, col := range columns {
+		if idx, ok := fieldIndexForColumn(fields, col); ok {
+			result.columns[i] = idx
+		} else {
+			unmapped = append(unmapped, col)
+		}
+	}
+	if strict && len(unmapped) > 0 {
+		return nil, fmt.Errorf("unmapped columns: %s", strings.Join(unmapped, ", "))
+	}
+	return result, nil
+}
+
+// fieldIndexForColumn finds the struct field for a result column - by exact name or, failing that,
+// case-insensitively ignoring underscores (so "created_at" matches "CreatedAt")
+func fieldIndexForColumn(fields []fieldMapping, column string) ([]int, bool) {
+	for _, f := range fields {
+		if f.name == column {
+			return f.index, true
+		}
+	}
+	normalized := normalizeColumnName(column)
+	for _, f := range fields {
+		if normalizeColumnName(f.name) == normalized {
+			return f.index, true
+		}
+	}
+	return nil, false
+}
+
+func normalizeColumnName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+func (s *rowScanner[T]) scan(rows *sql.Rows) (T, error) {
+	var result T
+	if s.scalar {
+		err := rows.Scan(&result)
+		return result, err
+	}
+	rv := reflect.ValueOf(&result).Elem()
+	if s.isPtr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	dest := make([]any, len(s.columns))
+	for i, idx := range s.columns {
+		if fv, ok := fieldForScan(rv, idx); ok {
+			dest[i] = fv.Addr().Interface()
+		} else {
+			dest[i] = new(any)
+		}
+	}
+	err := rows.Scan(dest...)
+	return result, err
+}
+
+// fieldForScan returns the (settable) field at the index - allocating any nil embedded struct pointers along the way
+//
+// returns false if there is no index (i.e. unmapped column) or a nil embedded struct pointer cannot be allocated (i.e. is unexported)
+func fieldForScan(rv reflect.Value, index []int) (reflect.Value, bool) {
+	if index == nil {
+		return rv, false
+	}
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return rv, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
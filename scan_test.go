@@ -0,0 +1,178 @@
+package sqlnt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type scanAudit struct {
+	CreatedAt time.Time
+}
+
+type ScanAuditPtr struct {
+	UpdatedBy *string `db:"updated_by"`
+}
+
+type scanPerson struct {
+	scanAudit
+	*ScanAuditPtr
+	Id       int    `db:"id"`
+	Name     string `db:"NAME"`
+	Nickname *string
+	Email    sql.NullString `json:"email_address"`
+	Ignored  string         `db:"-"`
+}
+
+func TestQueryInto(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM people WHERE status = :status`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	now := time.Now()
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "nickname", "email_address", "created_at", "updated_by", "other"}).
+			AddRow(1, "Bilbo", nil, nil, now, nil, "x").
+			AddRow(2, "Frodo", "Mr Underhill", "frodo@shire.me", now, "Sam", "y"))
+
+	people, err := QueryInto[scanPerson](context.Background(), nt, db, sql.Named("status", "active"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(people))
+	assert.Equal(t, 1, people[0].Id)
+	assert.Equal(t, "Bilbo", people[0].Name)
+	assert.Nil(t, people[0].Nickname)
+	assert.False(t, people[0].Email.Valid)
+	assert.Equal(t, now, people[0].CreatedAt)
+	assert.NotNil(t, people[0].ScanAuditPtr)
+	assert.Nil(t, people[0].UpdatedBy)
+	assert.Equal(t, 2, people[1].Id)
+	assert.Equal(t, "Frodo", people[1].Name)
+	assert.Equal(t, "Mr Underhill", *people[1].Nickname)
+	assert.Equal(t, "frodo@shire.me", people[1].Email.String)
+	assert.Equal(t, "Sam", *people[1].UpdatedBy)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueryInto_Pointers(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM people WHERE status = :status`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Bilbo"))
+
+	people, err := QueryInto[*scanPerson](context.Background(), nt, db, sql.Named("status", "active"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(people))
+	assert.Equal(t, 1, people[0].Id)
+	assert.Equal(t, "Bilbo", people[0].Name)
+}
+
+func TestQueryInto_Scalar(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT name FROM people WHERE status = :status`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Bilbo").AddRow("Frodo"))
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Bilbo").AddRow(nil))
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "other"}).AddRow("Bilbo", "x"))
+
+	names, err := QueryInto[string](context.Background(), nt, db, sql.Named("status", "active"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Bilbo", "Frodo"}, names)
+
+	nullNames, err := QueryInto[sql.NullString](context.Background(), nt, db, sql.Named("status", "active"))
+	require.NoError(t, err)
+	assert.Equal(t, []sql.NullString{{String: "Bilbo", Valid: true}, {}}, nullNames)
+
+	_, err = QueryInto[string](context.Background(), nt, db, sql.Named("status", "active"))
+	assert.Error(t, err)
+	assert.Equal(t, "cannot scan 2 columns into string", err.Error())
+}
+
+func TestQueryInto_Strict(t *testing.T) {
+	defer func() {
+		DefaultStrictScan = false
+	}()
+	DefaultStrictScan = true
+	nt := MustCreateNamedTemplate(`SELECT * FROM people WHERE status = :status`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "other", "another"}).AddRow(1, "Bilbo", "x", "y"))
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "other", "another"}).AddRow(1, "Bilbo", "x", "y"))
+
+	_, err = QueryInto[scanPerson](context.Background(), nt, db, sql.Named("status", "active"))
+	assert.Error(t, err)
+	assert.Equal(t, "unmapped columns: other, another", err.Error())
+	_, err = QueryOne[scanPerson](context.Background(), nt, db, sql.Named("status", "active"))
+	assert.Error(t, err)
+	assert.Equal(t, "unmapped columns: other, another", err.Error())
+}
+
+func TestQueryInto_Errors(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM people WHERE status = :status`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").WillReturnError(errors.New("fooey"))
+	mock.ExpectQuery(nt.Statement()).WithArgs("active").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("not an int"))
+
+	_, err = QueryInto[scanPerson](context.Background(), nt, db, map[string]any{})
+	assert.Error(t, err)
+	_, err = QueryInto[scanPerson](context.Background(), nt, db, sql.Named("status", "active"))
+	assert.Error(t, err)
+	assert.Equal(t, "fooey", err.Error())
+	_, err = QueryInto[scanPerson](context.Background(), nt, db, sql.Named("status", "active"))
+	assert.Error(t, err)
+}
+
+func TestQueryOne(t *testing.T) {
+	nt := MustCreateNamedTemplate(`SELECT * FROM people WHERE id = :id`)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(nt.Statement()).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Bilbo").AddRow(2, "Frodo"))
+	mock.ExpectQuery(nt.Statement()).WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
+	mock.ExpectQuery(nt.Statement()).WithArgs(4).WillReturnError(errors.New("fooey"))
+
+	person, err := QueryOne[scanPerson](context.Background(), nt, db, sql.Named("id", 1))
+	require.NoError(t, err)
+	assert.Equal(t, 1, person.Id)
+	assert.Equal(t, "Bilbo", person.Name)
+
+	_, err = QueryOne[scanPerson](context.Background(), nt, db, sql.Named("id", 3))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = QueryOne[scanPerson](context.Background(), nt, db, sql.Named("id", 4))
+	assert.Error(t, err)
+	assert.Equal(t, "fooey", err.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScanRows(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	mock.ExpectQuery(`SELECT * FROM people`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "other"}).AddRow(1, "x"))
+	mock.ExpectQuery(`SELECT * FROM people`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "other"}).AddRow(1, "x"))
+
+	rows, err := db.Query(`SELECT * FROM people`)
+	require.NoError(t, err)
+	people, err := ScanRows[scanPerson](rows, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(people))
+
+	rows, err = db.Query(`SELECT * FROM people`)
+	require.NoError(t, err)
+	_, err = ScanRows[scanPerson](rows, true)
+	assert.Error(t, err)
+	assert.Equal(t, "unmapped columns: other", err.Error())
+}
@@ -0,0 +1,167 @@
+package sqlnt
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const dbTag = "db"
+
+// structMapping is the (cached) reflection plan for mapping a struct type to named args
+type structMapping struct {
+	fields []fieldMapping
+}
+
+type fieldMapping struct {
+	name      string
+	index     []int
+	omitEmpty bool // denoted by `json:",omitempty"` - empty values are not mapped (as per json marshalling)
+}
+
+var (
+	structMappings   sync.Map
+	valuerType       = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	jsonMarshalerTyp = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// isMappableStruct determines whether the value is a struct (or pointer to struct) that can be mapped by reflection
+//
+// structs that implement json.Marshaler or driver.Valuer are not mapped by reflection
+func isMappableStruct(v reflect.Value) bool {
+	t := v.Type()
+	if t.Implements(jsonMarshalerTyp) || t.Implements(valuerType) {
+		return false
+	} else if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if reflect.PointerTo(t).Implements(jsonMarshalerTyp) || t.Implements(valuerType) {
+			return false
+		}
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// mapStruct adds each mapped field of the struct (or pointer to struct) to the supplied named args
+func mapStruct(v reflect.Value, result map[string]any) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	for _, f := range getStructMapping(v.Type()).fields {
+		if fv, err := v.FieldByIndexErr(f.index); err == nil && !(f.omitEmpty && isEmptyValue(fv)) {
+			result[f.name] = fieldValue(fv)
+		}
+	}
+}
+
+// fieldValue returns the value of a struct field - where pointers (that are not driver.Valuer) are dereferenced
+func fieldValue(fv reflect.Value) any {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
+		} else if !fv.Type().Implements(valuerType) {
+			return fv.Elem().Interface()
+		}
+	}
+	return fv.Interface()
+}
+
+func getStructMapping(t reflect.Type) *structMapping {
+	if m, ok := structMappings.Load(t); ok {
+		return m.(*structMapping)
+	}
+	m := &structMapping{fields: make([]fieldMapping, 0, t.NumField())}
+	m.addFields(t, nil, map[string]bool{})
+	actual, _ := structMappings.LoadOrStore(t, m)
+	return actual.(*structMapping)
+}
+
+func (m *structMapping) addFields(t reflect.Type, index []int, seen map[string]bool) {
+	embedded := make([]reflect.StructField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, tagged, omitEmpty, skip := fieldName(f)
+		if skip {
+			continue
+		} else if f.Anonymous && !tagged && isEmbeddable(f.Type) {
+			// embedded structs (without tag names) have their fields promoted - after the outer fields...
+			embedded = append(embedded, f)
+		} else if f.IsExported() && !seen[name] {
+			seen[name] = true
+			m.fields = append(m.fields, fieldMapping{
+				name:      name,
+				index:     append(append(make([]int, 0, len(index)+1), index...), i),
+				omitEmpty: omitEmpty,
+			})
+		}
+	}
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		m.addFields(ft, append(append(make([]int, 0, len(index)+1), index...), f.Index...), seen)
+	}
+}
+
+func isEmbeddable(t reflect.Type) bool {
+	if t.Implements(valuerType) {
+		return false
+	} else if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !t.Implements(valuerType)
+}
+
+// fieldName determines the named arg name for a struct field - from the `db` tag, `json` tag or field name (in that order)
+//
+// where the name is from the `json` tag, omitEmpty denotes whether the tag has the "omitempty" option
+func fieldName(f reflect.StructField) (name string, tagged bool, omitEmpty bool, skip bool) {
+	for _, tn := range []string{dbTag, "json"} {
+		if tag, ok := f.Tag.Lookup(tn); ok {
+			if tag == "-" {
+				return "", true, false, true
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name != "" {
+				return name, true, tn == "json" && hasTagOption(opts, "omitempty"), false
+			}
+		}
+	}
+	return f.Name, false, hasTagOption(jsonTagOptions(f), "omitempty"), false
+}
+
+func jsonTagOptions(f reflect.StructField) string {
+	_, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return opts
+}
+
+func hasTagOption(opts string, option string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptyValue determines whether the value is empty (as per json "omitempty")
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -37,8 +37,12 @@ func NewTemplateSet[T any](options ...any) (*T, error) {
 	if reflect.TypeOf(chk).Kind() != reflect.Struct {
 		return nil, errors.New("not a struct")
 	}
+	opts, err := getOptions(options...)
+	if err != nil {
+		return nil, err
+	}
 	r := new(T)
-	if err := setTemplateFields(reflect.ValueOf(r).Elem(), options...); err != nil {
+	if err = setTemplateFields(reflect.ValueOf(r).Elem(), "", opts); err != nil {
 		return nil, err
 	}
 	return r, nil
@@ -55,7 +59,9 @@ func MustCreateTemplateSet[T any](options ...any) *T {
 
 var ntt = reflect.TypeOf((*NamedTemplate)(nil)).Elem()
 
-func setTemplateFields(rv reflect.Value, options ...any) error {
+// setTemplateFields creates the templates for each field - where each template is named by its field path (prefixed by path)
+// as it is accessed (i.e. excluding embedded struct names)
+func setTemplateFields(rv reflect.Value, path string, opts *templateOptions) error {
 	rvt := rv.Type()
 	for i := 0; i < rv.NumField(); i++ {
 		fld := rv.Field(i)
@@ -69,20 +75,45 @@ func setTemplateFields(rv reflect.Value, options ...any) error {
 						return fmt.Errorf("field '%s' does not have '%s' tag", ft.Name, sqlTag)
 					}
 				}
-				if tmp, err := NewNamedTemplate(tag, options...); err == nil {
-					fld.Set(reflect.ValueOf(tmp))
+				fopts := *opts
+				fopts.name = TemplateName(path + ft.Name)
+				if tmp, err := newNamedTemplateFromOptions(tag, &fopts); err == nil {
+					fld.Set(reflect.ValueOf(NamedTemplate(tmp)))
 				} else {
-					return err
+					return withField(err, ft.Name)
 				}
 			} else if fld.Kind() == reflect.Struct {
 				sub := reflect.New(fld.Type()).Elem()
-				if err := setTemplateFields(sub, options...); err == nil {
+				subPath := path
+				if !ft.Anonymous {
+					// embedded structs are not part of the field path (as their fields are accessed directly)...
+					subPath += ft.Name + "."
+				}
+				if err := setTemplateFields(sub, subPath, opts); err == nil {
 					fld.Set(sub)
 				} else {
-					return err
+					return withField(err, ft.Name)
 				}
 			}
 		}
 	}
 	return nil
 }
+
+// withField sets (or prefixes) the field name on template errors
+func withField(err error, name string) error {
+	switch et := err.(type) {
+	case *ParseError:
+		et.Field = joinField(name, et.Field)
+	case *UnknownTokensError:
+		et.Field = joinField(name, et.Field)
+	}
+	return err
+}
+
+func joinField(name string, field string) string {
+	if field != "" {
+		return name + "." + field
+	}
+	return name
+}
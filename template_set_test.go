@@ -45,6 +45,14 @@ WHERE col_a = :a`
 	assert.Equal(t, "SELECT *\nFROM foo\nWHERE col_a = ?", ts.Select.Statement())
 }
 
+func TestNewTemplateSet_MarkerSyntax(t *testing.T) {
+	ts, err := NewTemplateSet[struct {
+		Select NamedTemplate `sql:"SELECT * FROM {{tableName}} WHERE col_a = @a"`
+	}](testTokenOption, AtMarkers, PostgresOption)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM foo WHERE col_a = $1", ts.Select.Statement())
+}
+
 func TestNewTemplateSet_Nested(t *testing.T) {
 	ts, err := NewTemplateSet[MySet2](testTokenOption)
 	assert.NoError(t, err)
@@ -80,7 +88,7 @@ type BadSet2 struct {
 func TestNewTemplateSet_Error_BadTemplate(t *testing.T) {
 	_, err := NewTemplateSet[BadSet2](testTokenOption)
 	assert.Error(t, err)
-	assert.Equal(t, "unknown token: unknown_token", err.Error())
+	assert.Equal(t, "field 'Select': unknown token: unknown_token (at line 1, column 1)", err.Error())
 }
 
 type BadSet3 struct {
@@ -90,7 +98,7 @@ type BadSet3 struct {
 func TestNewTemplateSet_Error_NestedBadTemplate(t *testing.T) {
 	_, err := NewTemplateSet[BadSet3](testTokenOption)
 	assert.Error(t, err)
-	assert.Equal(t, "unknown token: unknown_token", err.Error())
+	assert.Equal(t, "field 'BadSet2.Select': unknown token: unknown_token (at line 1, column 1)", err.Error())
 }
 
 func TestMustCreateTemplateSet(t *testing.T) {
@@ -103,3 +111,72 @@ func TestMustCreateTemplateSet_Panics(t *testing.T) {
 		_ = MustCreateTemplateSet[BadSet1]()
 	})
 }
+
+func TestNewTemplateSet_StrictArgs(t *testing.T) {
+	ts, err := NewTemplateSet[MySet](testTokenOption, UnknownArgsError)
+	assert.NoError(t, err)
+	_, err = ts.Select.Args(map[string]any{"a": "aa", "b": "bb"})
+	assert.Error(t, err)
+	assert.Equal(t, "template 'Select': unknown arg: b", err.Error())
+}
+
+type BadSet4 struct {
+	Select NamedTemplate `SELECT *
+FROM {{tableName}}
+WHERE col_a = :`
+}
+
+type BadSet5 struct {
+	Nested BadSet4
+}
+
+func TestNewTemplateSet_Error_ParseErrorField(t *testing.T) {
+	_, err := NewTemplateSet[BadSet4](testTokenOption)
+	assert.Error(t, err)
+	assert.Equal(t, `field 'Select': named marker ':' without name (at line 3, column 15: "WHERE col_a = :")`, err.Error())
+	perr, ok := err.(*ParseError)
+	assert.True(t, ok)
+	assert.Equal(t, "Select", perr.Field)
+	assert.Equal(t, 3, perr.Line)
+
+	_, err = NewTemplateSet[BadSet5](testTokenOption)
+	assert.Error(t, err)
+	assert.Equal(t, `field 'Nested.Select': named marker ':' without name (at line 3, column 15: "WHERE col_a = :")`, err.Error())
+}
+
+type NamedSet struct {
+	Users struct {
+		Select NamedTemplate `sql:"SELECT * FROM users WHERE id = :id"`
+	}
+	Delete NamedTemplate `sql:"DELETE FROM users WHERE id = :id"`
+}
+
+func TestNewTemplateSet_Names(t *testing.T) {
+	ts, err := NewTemplateSet[NamedSet](TemplateName("ignored"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Users.Select", ts.Users.Select.Name())
+	assert.Equal(t, "Delete", ts.Delete.Name())
+	_, err = ts.Users.Select.Args(map[string]any{})
+	assert.Error(t, err)
+	assert.Equal(t, "template 'Users.Select': named arg 'id' missing", err.Error())
+
+	ts3, err := NewTemplateSet[MySet3](testTokenOption)
+	assert.NoError(t, err)
+	assert.Equal(t, "Select", ts3.Select.Name())
+	assert.Equal(t, "Insert", ts3.Insert.Name())
+
+	type nested struct {
+		Orders struct {
+			MySet
+		}
+	}
+	ts4, err := NewTemplateSet[nested](testTokenOption)
+	assert.NoError(t, err)
+	assert.Equal(t, "Orders.Select", ts4.Orders.Select.Name())
+}
+
+func TestNewTemplateSet_InvalidOption(t *testing.T) {
+	_, err := NewTemplateSet[NamedSet](true)
+	assert.Error(t, err)
+	assert.Equal(t, "invalid option", err.Error())
+}